@@ -0,0 +1,185 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// defaultMaxBindBodySize is the largest url-encoded body read by [Bind],
+// unless changed with [WithMaxBodySize]. It matches the limit applied by
+// [net/http.Request.ParseForm].
+const defaultMaxBindBodySize = 10 << 20
+
+// defaultMaxBindMultipartSize is the largest multipart body read by [Bind],
+// unless changed with [WithMaxBodySize]. It matches the default memory
+// threshold, so that a default Bind cannot be made to fill the disk.
+const defaultMaxBindMultipartSize = defaultMaxMemory
+
+// A QueryPrecedence determines how [Bind] combines the URL query of a request
+// with its body. Where both have the same key, the value of the source that
+// takes precedence replaces the other entirely, including every element of a
+// slice. Keys found in only one source are decoded either way.
+type QueryPrecedence int
+
+const (
+	// BodyOverQuery gives the body precedence over the query.
+	BodyOverQuery QueryPrecedence = iota
+
+	// QueryOverBody gives the query precedence over the body.
+	QueryOverBody
+
+	// BodyOnly ignores the query altogether.
+	BodyOnly
+)
+
+// WithQueryPrecedence sets how [Bind] combines the URL query of a request with
+// its body. The default is [BodyOverQuery].
+func WithQueryPrecedence(p QueryPrecedence) Option {
+	return func(o *options) {
+		o.queryPrecedence = p
+	}
+}
+
+// A BindError is returned by [Bind] when a request cannot be bound. StatusCode
+// is the HTTP status the error maps to: 415 Unsupported Media Type when the
+// Content-Type of the body cannot be decoded, and 400 Bad Request when the
+// request is malformed, too large or does not match the destination.
+type BindError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *BindError) Unwrap() error { return e.Err }
+
+// Bind decodes the URL query and the body of r into the struct or map pointed
+// to by v. The body is decoded according to its Content-Type, which must be
+// application/x-www-form-urlencoded or multipart/form-data. A request without
+// a body, or without a Content-Type and with an empty body, binds the query
+// alone. How the two are combined is set with [WithQueryPrecedence].
+//
+// The body is read through [http.MaxBytesReader], limited to the size set with
+// [WithMaxBodySize]. Without it, a url-encoded body is limited to 10 MB as by
+// [http.Request.ParseForm], and a multipart body to 32 MB.
+// The form of a multipart body is stored in r.MultipartForm, so that the
+// server removes any temporary files once the handler returns.
+//
+// Errors caused by the request are returned as a [BindError]. Any other error,
+// such as a v that is not a pointer to a struct or map, is returned as is.
+func Bind(r *http.Request, v any, opts ...Option) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if !isCompositePointer(val) {
+		return fmt.Errorf("form: cannot bind request into %v", val.Type())
+	}
+
+	d := &decodeState{seen: map[string]bool{}, opts: newOptions(opts)}
+
+	// The source with precedence is decoded last, each with its own record of
+	// the keys it has seen, so that its values replace those of the other.
+	sources := []func() error{
+		func() error { return bindQuery(d, r, val) },
+		func() error { return bindBody(d, r, val) },
+	}
+	switch d.opts.queryPrecedence {
+	case QueryOverBody:
+		sources[0], sources[1] = sources[1], sources[0]
+	case BodyOnly:
+		sources = sources[1:]
+	}
+	for _, bind := range sources {
+		clear(d.seen)
+		if err := bind(); err != nil {
+			return bindError(err)
+		}
+	}
+
+	if err := d.finish(); err != nil {
+		return &BindError{StatusCode: http.StatusBadRequest, Err: err}
+	}
+	return nil
+}
+
+func bindQuery(d *decodeState, r *http.Request, v reflect.Value) error {
+	if r.URL == nil || r.URL.RawQuery == "" {
+		return nil
+	}
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return fmt.Errorf("form: invalid query: %w", err)
+	}
+	return unmarshalValues(d, query, v)
+}
+
+func bindBody(d *decodeState, r *http.Request, v reflect.Value) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		if r.ContentLength == 0 {
+			return nil
+		}
+		return &BindError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Err:        errors.New("form: missing Content-Type"),
+		}
+	}
+	mediatype, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("form: invalid Content-Type: %w", err)
+	}
+
+	switch mediatype {
+	case "application/x-www-form-urlencoded":
+		n := d.opts.maxBodySize
+		if n <= 0 {
+			n = defaultMaxBindBodySize
+		}
+		body := http.MaxBytesReader(nil, r.Body, n)
+		return unmarshalPairs(d, newScanner(body, &d.opts), v)
+
+	case "multipart/form-data":
+		n := d.opts.maxBodySize
+		if n <= 0 {
+			n = defaultMaxBindMultipartSize
+		}
+		body := http.MaxBytesReader(nil, r.Body, n)
+		md := &MultipartDecoder{r: body, boundary: ct, opts: d.opts}
+		form, err := md.readForm()
+		if err != nil {
+			return err
+		}
+		r.MultipartForm = form
+		if err := unmarshalValues(d, form.Value, v); err != nil {
+			return err
+		}
+		return unmarshalFiles(d, form.File, v)
+	}
+
+	return &BindError{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Err:        fmt.Errorf("form: unsupported Content-Type %q", mediatype),
+	}
+}
+
+// bindError returns err as a BindError, mapping it to 400 Bad Request unless
+// it already is one.
+func bindError(err error) error {
+	var bindErr *BindError
+	if errors.As(err, &bindErr) {
+		return err
+	}
+	return &BindError{StatusCode: http.StatusBadRequest, Err: err}
+}
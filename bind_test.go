@@ -0,0 +1,271 @@
+package encoding_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tomasbasham/encoding"
+)
+
+func newRequest(method, target, contentType string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+const urlencoded = "application/x-www-form-urlencoded"
+
+// largeMultipartBody returns a multipart body with boundary x holding a single
+// file of n zero bytes, streamed rather than held in memory.
+func largeMultipartBody(n int64) io.Reader {
+	return io.MultiReader(
+		strings.NewReader("--x\r\nContent-Disposition: form-data; name=\"avatar\"; filename=\"big.bin\"\r\n\r\n"),
+		io.LimitReader(zeros{}, n),
+		strings.NewReader("\r\n--x--\r\n"),
+	)
+}
+
+// zeros is an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestBind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		opts    []encoding.Option
+		want    BasicForm
+	}{
+		{
+			name:    "url-encoded body",
+			request: newRequest("POST", "/", urlencoded, strings.NewReader("name=john&age=30&aliases=jon")),
+			want:    BasicForm{Name: "john", Aliases: []string{"jon"}, Age: 30},
+		},
+		{
+			name:    "content type with parameters",
+			request: newRequest("POST", "/", urlencoded+"; charset=utf-8", strings.NewReader("name=john")),
+			want:    BasicForm{Name: "john"},
+		},
+		{
+			name:    "query without body",
+			request: newRequest("GET", "/?name=john&age=30", "", nil),
+			want:    BasicForm{Name: "john", Age: 30},
+		},
+		{
+			name:    "query without content type",
+			request: newRequest("POST", "/?name=john", "", strings.NewReader("")),
+			want:    BasicForm{Name: "john"},
+		},
+		{
+			name: "body over query",
+			request: newRequest("POST", "/?name=query&age=30&aliases=a&aliases=b", urlencoded,
+				strings.NewReader("name=body&aliases=c")),
+			want: BasicForm{Name: "body", Aliases: []string{"c"}, Age: 30},
+		},
+		{
+			name: "query over body",
+			request: newRequest("POST", "/?name=query&aliases=a&aliases=b", urlencoded,
+				strings.NewReader("name=body&age=30&aliases=c")),
+			opts: []encoding.Option{encoding.WithQueryPrecedence(encoding.QueryOverBody)},
+			want: BasicForm{Name: "query", Aliases: []string{"a", "b"}, Age: 30},
+		},
+		{
+			name:    "body only",
+			request: newRequest("POST", "/?name=query&age=30", urlencoded, strings.NewReader("name=body")),
+			opts:    []encoding.Option{encoding.WithQueryPrecedence(encoding.BodyOnly)},
+			want:    BasicForm{Name: "body"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got BasicForm
+			if err := encoding.Bind(tt.request, &got, tt.opts...); err != nil {
+				t.Fatalf("Bind() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Bind() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestBind_MapOfStructs(t *testing.T) {
+	t.Parallel()
+
+	type Pref struct {
+		X string `form:"x"`
+		Y string `form:"y"`
+	}
+	type PrefsForm struct {
+		Prefs map[string]Pref `form:"prefs"`
+	}
+
+	tests := []struct {
+		name string
+		opts []encoding.Option
+		want PrefsForm
+	}{
+		{
+			name: "body over query",
+			want: PrefsForm{Prefs: map[string]Pref{"a": {X: "q", Y: "b"}, "b": {Y: "b"}}},
+		},
+		{
+			name: "query over body",
+			opts: []encoding.Option{encoding.WithQueryPrecedence(encoding.QueryOverBody)},
+			want: PrefsForm{Prefs: map[string]Pref{"a": {X: "q", Y: "b"}, "b": {Y: "q"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newRequest("POST", "/?prefs[a][x]=q&prefs[b][y]=q", urlencoded,
+				strings.NewReader("prefs[a][y]=b&prefs[b][y]=b"))
+			var got PrefsForm
+			if err := encoding.Bind(r, &got, tt.opts...); err != nil {
+				t.Fatalf("Bind() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Bind() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestBind_Multipart(t *testing.T) {
+	t.Parallel()
+
+	body, w := multipartBody(t,
+		part{name: "title", content: "holiday"},
+		part{name: "avatar", filename: "me.png", content: "png data"},
+	)
+	r := newRequest("POST", "/?title=query&tags=beach", w.FormDataContentType(), body)
+
+	var got UploadForm
+	if err := encoding.Bind(r, &got); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	if diff := diff("holiday", got.Title); diff != "" {
+		t.Errorf("Bind() title mismatch %s", diff)
+	}
+	if diff := diff([]string{"beach"}, got.Tags); diff != "" {
+		t.Errorf("Bind() tags mismatch %s", diff)
+	}
+	if got.Avatar == nil {
+		t.Fatal("Bind() avatar = nil")
+	}
+	if diff := diff("png data", readFile(t, got.Avatar)); diff != "" {
+		t.Errorf("Bind() avatar content mismatch %s", diff)
+	}
+}
+
+func TestBind_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		request    *http.Request
+		opts       []encoding.Option
+		wantStatus int
+	}{
+		{
+			name:       "unsupported content type",
+			request:    newRequest("POST", "/", "application/json", strings.NewReader(`{"name":"john"}`)),
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "missing content type",
+			request:    newRequest("POST", "/", "", strings.NewReader("name=john")),
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "invalid content type",
+			request:    newRequest("POST", "/", "text/", strings.NewReader("name=john")),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid body",
+			request:    newRequest("POST", "/", urlencoded, strings.NewReader("name=%zz")),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid query",
+			request:    newRequest("GET", "/?name=%zz", "", nil),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "type error",
+			request:    newRequest("POST", "/?age=thirty", urlencoded, strings.NewReader("name=john")),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "body too large",
+			request:    newRequest("POST", "/", urlencoded, strings.NewReader("name=john&age=30")),
+			opts:       []encoding.Option{encoding.WithMaxBodySize(8)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multipart body too large",
+			request:    newRequest("POST", "/", "multipart/form-data; boundary=x", largeMultipartBody(1<<10)),
+			opts:       []encoding.Option{encoding.WithMaxBodySize(512)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multipart body over default limit",
+			request:    newRequest("POST", "/", "multipart/form-data; boundary=x", largeMultipartBody(33<<20)),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown fields",
+			request:    newRequest("POST", "/?page=2", urlencoded, strings.NewReader("name=john")),
+			opts:       []encoding.Option{encoding.WithDisallowUnknownFields()},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid multipart body",
+			request:    newRequest("POST", "/", "multipart/form-data; boundary=x", strings.NewReader("name=john")),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Bind(tt.request, &BasicForm{}, tt.opts...)
+			var bindErr *encoding.BindError
+			if !errors.As(err, &bindErr) {
+				t.Fatalf("Bind() error = %v, want *BindError", err)
+			}
+			if bindErr.StatusCode != tt.wantStatus {
+				t.Errorf("BindError.StatusCode = %d, want %d", bindErr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("invalid target", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRequest("POST", "/", urlencoded, strings.NewReader("name=john"))
+		err := encoding.Bind(r, new(int))
+		var bindErr *encoding.BindError
+		if err == nil || errors.As(err, &bindErr) {
+			t.Errorf("Bind() error = %v, want an error other than *BindError", err)
+		}
+	})
+}
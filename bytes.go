@@ -0,0 +1,95 @@
+package encoding
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A bytesEncoding is how a byte slice or array is written as a single value,
+// as set by the hex, base64 or base64url option in the tag of its field:
+//
+//	Checksum [32]byte `form:"checksum,hex"`
+//	Token    []byte   `form:"token,base64url"`
+//
+// Without one, a byte slice is written in base64 and a byte array like any
+// other array, one number for each byte.
+type bytesEncoding int
+
+const (
+	noBytesEncoding bytesEncoding = iota
+	hexBytes                      // hexadecimal, as by encoding/hex
+	base64Bytes                   // standard base64, padded
+	base64URLBytes                // URL-safe base64, unpadded
+)
+
+func (b bytesEncoding) encodeToString(src []byte) string {
+	switch b {
+	case hexBytes:
+		return hex.EncodeToString(src)
+	case base64URLBytes:
+		return base64.RawURLEncoding.EncodeToString(src)
+	}
+	return base64.StdEncoding.EncodeToString(src)
+}
+
+// decodeString decodes s. Base64 is accepted with or without padding.
+func (b bytesEncoding) decodeString(s string) ([]byte, error) {
+	switch b {
+	case hexBytes:
+		return hex.DecodeString(s)
+	case base64URLBytes:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// encode writes the byte slice or array v under key.
+func (b bytesEncoding) encode(e *encodeState, key string, v reflect.Value) error {
+	if v.Kind() == reflect.Slice {
+		return e.w.writePair(key, b.encodeToString(v.Bytes()))
+	}
+
+	// A byte array that cannot be addressed cannot be sliced either, so it is
+	// copied out.
+	src := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(src), v)
+	return e.w.writePair(key, b.encodeToString(src))
+}
+
+// set assigns the bytes encoded in val to the byte slice or array v. They
+// must fill an array exactly, and an empty value leaves it zero.
+func (b bytesEncoding) set(v reflect.Value, val string) error {
+	src, err := b.decodeString(val)
+	if err != nil {
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: err}
+	}
+	if v.Kind() == reflect.Slice {
+		v.SetBytes(src)
+		return nil
+	}
+
+	if val == "" {
+		v.SetZero()
+		return nil
+	}
+	if len(src) != v.Len() {
+		err := fmt.Errorf("decoded %d bytes, want %d", len(src), v.Len())
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: err}
+	}
+	reflect.Copy(v, reflect.ValueOf(src))
+	return nil
+}
+
+// isByteArray reports whether t is an array of bytes.
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+}
+
+// isByteSlice reports whether t is a slice of bytes, which is written as a
+// single value rather than one value for each byte.
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
@@ -0,0 +1,280 @@
+package encoding_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tomasbasham/encoding"
+)
+
+type DigestForm struct {
+	Checksum [4]byte   `form:"checksum,hex"`
+	Key      [4]byte   `form:"key,base64"`
+	Parts    [][2]byte `form:"parts,hex,omitempty"`
+	Salt     *[2]byte  `form:"salt,base64,omitempty"`
+}
+
+type TokenForm struct {
+	Data   []byte   `form:"data,omitempty"`
+	Sig    []byte   `form:"sig,hex,omitempty"`
+	Token  []byte   `form:"token,base64url,omitempty"`
+	Chunks [][]byte `form:"chunks,omitempty"`
+}
+
+func TestMarshal_ByteSlices(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input any
+		want  []byte
+	}{
+		{
+			name: "tag options",
+			input: TokenForm{
+				Data:  []byte("hello?"),
+				Sig:   []byte{0xde, 0xad},
+				Token: []byte{0xfb, 0xff},
+			},
+			want: pairsToBytes(
+				"data", "aGVsbG8/",
+				"sig", "dead",
+				"token", "-_8",
+			),
+		},
+		{
+			name:  "slice of byte slices",
+			input: TokenForm{Chunks: [][]byte{[]byte("a"), []byte("bc")}},
+			want:  pairsToBytes("chunks", "YQ==", "chunks", "YmM="),
+		},
+		{
+			name:  "top-level value",
+			input: []byte("hi"),
+			want:  []byte("aGk%3D"),
+		},
+		{
+			name:  "map value",
+			input: map[string][]byte{"a": []byte("hi")},
+			want:  []byte("a=aGk%3D"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ByteSlices(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   []byte
+		target  any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:   "tag options",
+			input:  []byte("data=aGVsbG8%2F&sig=DEAD&token=-_8"),
+			target: &TokenForm{},
+			want: &TokenForm{
+				Data:  []byte("hello?"),
+				Sig:   []byte{0xde, 0xad},
+				Token: []byte{0xfb, 0xff},
+			},
+		},
+		{
+			name:   "padding is optional",
+			input:  []byte("data=aGk&token=-_8%3D"),
+			target: &TokenForm{},
+			want:   &TokenForm{Data: []byte("hi"), Token: []byte{0xfb, 0xff}},
+		},
+		{
+			name:   "slice of byte slices",
+			input:  []byte("chunks=YQ%3D%3D&chunks=YmM%3D"),
+			target: &TokenForm{},
+			want:   &TokenForm{Chunks: [][]byte{[]byte("a"), []byte("bc")}},
+		},
+		{
+			name:   "top-level value",
+			input:  []byte("aGk%3D"),
+			target: new([]byte),
+			want:   pointerTo([]byte("hi")),
+		},
+		{
+			name:   "empty value",
+			input:  []byte("data="),
+			target: &TokenForm{},
+			want:   &TokenForm{Data: []byte{}},
+		},
+		{
+			name:    "invalid base64",
+			input:   []byte("data=%21%21"),
+			target:  &TokenForm{},
+			wantErr: true,
+		},
+		{
+			name:    "standard alphabet for base64url",
+			input:   []byte("token=%2B%2F8"),
+			target:  &TokenForm{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				var typeErr *encoding.UnmarshalTypeError
+				if !errors.As(err, &typeErr) {
+					t.Errorf("Unmarshal() error = %v, want *UnmarshalTypeError", err)
+				}
+				return
+			}
+			if diff := diff(tt.want, tt.target); diff != "" {
+				t.Errorf("Unmarshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshal_ByteArrays(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input any
+		want  []byte
+	}{
+		{
+			name: "hex and base64",
+			input: DigestForm{
+				Checksum: [4]byte{0xde, 0xad, 0xbe, 0xef},
+				Key:      [4]byte{1, 2, 3, 4},
+			},
+			want: pairsToBytes(
+				"checksum", "deadbeef",
+				"key", "AQIDBA==",
+			),
+		},
+		{
+			name: "slices and pointers",
+			input: DigestForm{
+				Parts: [][2]byte{{0xca, 0xfe}, {0xba, 0xbe}},
+				Salt:  &[2]byte{0xff, 0xff},
+			},
+			want: pairsToBytes(
+				"checksum", "00000000",
+				"key", "AAAAAA==",
+				"parts", "cafe",
+				"parts", "babe",
+				"salt", "//8=",
+			),
+		},
+		{
+			name:  "map value",
+			input: map[string]DigestForm{"a": {Checksum: [4]byte{1, 2, 3, 4}}},
+			want:  []byte("a%5Bchecksum%5D=01020304&a%5Bkey%5D=AAAAAA%3D%3D"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ByteArrays(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     []byte
+		want      DigestForm
+		wantField string
+	}{
+		{
+			name:  "hex and base64",
+			input: []byte("checksum=DEADBEEF&key=AQIDBA%3D%3D"),
+			want: DigestForm{
+				Checksum: [4]byte{0xde, 0xad, 0xbe, 0xef},
+				Key:      [4]byte{1, 2, 3, 4},
+			},
+		},
+		{
+			name:  "slices and pointers",
+			input: []byte("parts=cafe&parts=babe&salt=%2F%2F8%3D"),
+			want: DigestForm{
+				Parts: [][2]byte{{0xca, 0xfe}, {0xba, 0xbe}},
+				Salt:  &[2]byte{0xff, 0xff},
+			},
+		},
+		{
+			name:  "empty value",
+			input: []byte("checksum="),
+			want:  DigestForm{},
+		},
+		{
+			name:      "invalid hex",
+			input:     []byte("checksum=xyz"),
+			wantField: "checksum",
+		},
+		{
+			name:      "too few bytes",
+			input:     []byte("checksum=dead"),
+			wantField: "checksum",
+		},
+		{
+			name:      "too many bytes",
+			input:     []byte("parts=cafe&parts=cafebabe"),
+			wantField: "parts[1]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got DigestForm
+			err := encoding.Unmarshal(tt.input, &got)
+			if tt.wantField != "" {
+				var typeErr *encoding.UnmarshalTypeError
+				if !errors.As(err, &typeErr) {
+					t.Fatalf("Unmarshal() error = %v, want *UnmarshalTypeError", err)
+				}
+				if typeErr.Field != tt.wantField {
+					t.Errorf("UnmarshalTypeError.Field = %q, want %q", typeErr.Field, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Unmarshal() mismatch %s", diff)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package encoding
 
 import (
+	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -25,6 +30,58 @@ func (e *InvalidUnmarshalError) Error() string {
 	return "form: Unmarshal(nil " + e.Type.String() + ")"
 }
 
+// An UnmarshalTypeError describes a form value that was not appropriate for a
+// value of a specific Go type.
+type UnmarshalTypeError struct {
+	Field string       // the full key of the value, such as user[address][zip]
+	Value string       // the raw form value
+	Type  reflect.Type // type of Go value it could not be assigned to
+	Err   error        // the reason the value was rejected
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	msg := "form: cannot unmarshal " + strconv.Quote(e.Value)
+	if e.Field != "" {
+		msg += " into field " + e.Field
+	}
+	msg += " of type " + e.Type.String()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *UnmarshalTypeError) Unwrap() error { return e.Err }
+
+// FieldErrors is returned by [Unmarshal] when decoding with [WithAllErrors]
+// and one or more keys could not be decoded. It holds one error for each
+// failing key, in the order they were decoded, and can be inspected with [errors.As] like any
+// error joined by [errors.Join].
+type FieldErrors []error
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the individual keys.
+func (e FieldErrors) Unwrap() []error { return e }
+
+// An UnknownFieldsError is returned when decoding with
+// [WithDisallowUnknownFields] and the input has keys that do not match the
+// destination. Keys lists every such key, in the order they were decoded.
+type UnknownFieldsError struct {
+	Keys []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return "form: unknown fields: " + strings.Join(e.Keys, ", ")
+}
+
 // Unmarshaler is the interface implemented by types that can unmarshal a form
 // description of themselves. The input can be assumed to be a valid encoding of
 // a form value. UnmarshalForm must copy the form data if it wishes to retain
@@ -35,134 +92,510 @@ type Unmarshaler interface {
 
 // Unmarshal parses the form data and stores the result in the value pointed to
 // by v. If v is nil or not a pointer, Unmarshal returns an InvalidValueError.
-func Unmarshal(data []byte, v any) error {
+//
+// A value that implements [Unmarshaler] is decoded by its UnmarshalForm method
+// and, failing that, one that implements [encoding.TextUnmarshaler] by its
+// UnmarshalText method. Either receives the value of a single key. Map keys
+// must be strings, booleans, numbers or implement encoding.TextUnmarshaler, and
+// are parsed as values of their type would be.
+//
+// A [time.Time] is read in the format set by the tag of its field, as described
+// for [Marshal], and a [time.Duration] as by [time.ParseDuration]. An empty
+// value of either leaves it zero.
+//
+// A byte slice is read from a single value in the encoding it is written in by
+// [Marshal]. Base64 is accepted with or without padding. Arrays are read like
+// slices, except that more values than an array can hold are an error. A byte
+// array whose field sets an encoding is read from a single value, which must
+// decode to exactly as many bytes as it holds.
+func Unmarshal(data []byte, v any, opts ...Option) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Pointer || val.IsNil() {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
+
+	d := &decodeState{seen: map[string]bool{}, opts: newOptions(opts)}
+	s := newScanner(bytes.NewReader(data), &d.opts)
 	if u, ok := v.(Unmarshaler); ok {
-		return u.UnmarshalForm(data)
+		return unmarshalSelf(s, u)
 	}
-
-	return unmarshal(data, val)
+	return unmarshal(d, s, val)
 }
 
-func unmarshal(data []byte, v reflect.Value) error {
-	if !isCompositePointer(v) {
-		return unmarshalPrimitive(data, v)
+// UnmarshalValues stores the pairs of data in the value pointed to by v, which
+// must be a struct, a map or an [Unmarshaler]. It decodes the pairs as
+// [Unmarshal] would and returns the same errors, but works on form data that
+// has already been parsed, such as the PostForm of an [net/http.Request] after a
+// call to ParseForm. Since url.Values does not keep the order of its keys,
+// they are decoded sorted by key, and the values of each key in order.
+func UnmarshalValues(data url.Values, v any, opts ...Option) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
-
-	values, err := url.ParseQuery(string(data))
-	if err != nil {
-		return fmt.Errorf("form: invalid form data: %w", err)
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalForm([]byte(data.Encode()))
 	}
-
-	if isStructPointer(v) {
-		return unmarshalValue(values, v, unmarshalStruct)
+	if !isCompositePointer(val) {
+		return fmt.Errorf("form: cannot decode url.Values into %v", val.Type())
 	}
-	if isMapPointer(v) {
-		return unmarshalValue(values, v, unmarshalMap)
+
+	d := &decodeState{seen: map[string]bool{}, opts: newOptions(opts)}
+	if err := unmarshalValues(d, data, val); err != nil {
+		return err
 	}
-	return nil
+	return d.finish()
 }
 
-func isStructPointer(v reflect.Value) bool {
-	return v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct
+// unmarshal decodes the pairs read by s into v, which must be a non-nil
+// pointer. Each pair is assigned as soon as it has been read.
+func unmarshal(d *decodeState, s *scanner, v reflect.Value) error {
+	if !isCompositePointer(v) {
+		return unmarshalPrimitive(s, v)
+	}
+	if err := unmarshalPairs(d, s, v); err != nil {
+		return err
+	}
+	return d.finish()
 }
 
-func isMapPointer(v reflect.Value) bool {
-	return v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Map
+// unmarshalSelf decodes the form data read by s with the UnmarshalForm method
+// of u, within the same limits as any other value. A [Values] is filled pair
+// by pair as they are read, and any other Unmarshaler given the whole input.
+func unmarshalSelf(s *scanner, u Unmarshaler) error {
+	if v, ok := u.(*Values); ok {
+		return v.scan(s)
+	}
+	body, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	return u.UnmarshalForm(body)
 }
 
+// isCompositePointer reports whether v points to a struct or map that is
+// built from the keys of the form, rather than one that decodes itself.
 func isCompositePointer(v reflect.Value) bool {
-	return isStructPointer(v) || isMapPointer(v)
+	if v.Kind() != reflect.Pointer {
+		return false
+	}
+	t := v.Type().Elem()
+	return (t.Kind() == reflect.Struct || t.Kind() == reflect.Map) && isNestable(t)
 }
 
-func unmarshalPrimitive(data []byte, v reflect.Value) error {
-	if len(data) == 0 {
+func unmarshalPrimitive(s *scanner, v reflect.Value) error {
+	s.valuesOnly = true
+
+	var values []string
+	for {
+		p, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		values = append(values, p.Value)
+	}
+
+	if len(values) == 0 {
+		switch rv := v.Elem(); rv.Kind() {
+		case reflect.Slice:
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		case reflect.Array:
+			rv.SetZero()
+		}
 		return nil
 	}
+	return set(v.Elem(), values)
+}
 
-	unescaped, err := url.QueryUnescape(string(data))
-	if err != nil {
-		return fmt.Errorf("form: invalid form data: %w", err)
+// unmarshalPairs assigns the pairs read by s to v in the order they appear.
+func unmarshalPairs(d *decodeState, s *scanner, v reflect.Value) error {
+	rv := reflect.Indirect(v)
+	if rv.Kind() == reflect.Map && rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
 	}
 
-	var allValues []string
-	if strings.Contains(unescaped, "&") {
-		parts := strings.Split(string(data), "&")
-		allValues = make([]string, len(parts))
-		for _, part := range parts {
-			val, err := url.QueryUnescape(part)
-			if err != nil {
-				return fmt.Errorf("form: invalid form data: %w", err)
+	for {
+		p, err := s.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		d.key = p.Key
+		segments := d.opts.keySyntax.split(p.Key)
+		if err := unmarshalPath(d, rv, "", segments, assignString(d, p.Value)); err != nil {
+			if err := d.addError(err); err != nil {
+				return err
 			}
-			allValues = append(allValues, val)
 		}
-	} else {
-		allValues = []string{unescaped}
 	}
+}
 
-	return set(v.Elem(), allValues)
+func unmarshalValues(d *decodeState, data url.Values, v reflect.Value) error {
+	rv := reflect.Indirect(v)
+	if rv.Kind() == reflect.Map && rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	// Walk the keys in a stable order so that the outcome of repeated or
+	// overlapping keys does not depend on map iteration order.
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		d.key = key
+		segments := d.opts.keySyntax.split(key)
+		for _, val := range data[key] {
+			if err := unmarshalPath(d, rv, "", segments, assignString(d, val)); err != nil {
+				if err := d.addError(err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
 
-type unmarshalerFunc func(url.Values, reflect.Value) error
+// decodeState holds the state of a single call to [Unmarshal]. Each key-value
+// pair is assigned on its own, so seen records every path that has already
+// been initialised: the first value of a repeated scalar key wins, and a slice
+// is reset before its first element is appended.
+//
+// The key being decoded is kept in key so that it can be recorded in unknown
+// if it turns out not to match the destination. When every error is
+// collected, errs holds those of the keys decoded so far.
+type decodeState struct {
+	seen    map[string]bool
+	opts    options
+	key     string
+	unknown []string
+	errs    FieldErrors
+
+	// field is the struct field being decoded, whose tag may set the format
+	// of the values below it.
+	field *field
+
+	// lens holds the number of values assigned to each array, by path.
+	lens map[string]int
+
+	// elems holds the paths of the map elements assigned so far. Unlike seen,
+	// it is kept across the sources bound by [Bind], so that an element built
+	// from one source is added to rather than replaced by the next.
+	elems map[string]bool
+}
 
-func unmarshalValue(data url.Values, v reflect.Value, fn unmarshalerFunc) error {
-	rv := reflect.Indirect(v)
-	err := fn(data, rv)
-	if err != nil {
+// addError records the error of a key that could not be decoded. It returns
+// the error, wrapped, unless every error is being collected.
+func (d *decodeState) addError(err error) error {
+	if !d.opts.allErrors {
 		return fmt.Errorf("form: failed to unmarshal: %w", err)
 	}
+	d.errs = append(d.errs, err)
 	return nil
 }
 
-func unmarshalStruct(data url.Values, v reflect.Value) error {
-	tags := tags(v)
-	for i := range v.Type().NumField() {
-		fv := v.Field(i)
-		if !fv.CanSet() {
-			continue
-		}
-		tag := tags[i]
-		if tag.Ignore {
-			continue
-		}
-		key := tag.Name
-		if key == "-" {
-			continue
+// unknownKey records that the key being decoded does not match the
+// destination. A key with several values is recorded once.
+func (d *decodeState) unknownKey() {
+	if !d.opts.disallowUnknownFields {
+		return
+	}
+	if slices.Contains(d.unknown, d.key) {
+		return
+	}
+	d.unknown = append(d.unknown, d.key)
+}
+
+// finish returns the error of a decode that ran to the end: the unknown keys,
+// or the collected errors, or nil if there are none.
+func (d *decodeState) finish() error {
+	if len(d.unknown) > 0 {
+		err := &UnknownFieldsError{Keys: d.unknown}
+		if !d.opts.allErrors {
+			return err
 		}
-		if val, ok := data[key]; ok {
-			if err := set(fv, val); err != nil {
-				return fmt.Errorf("form: failed to set field %s: %w", tag.Name, err)
-			}
+		d.errs = append(d.errs, err)
+	}
+	if len(d.errs) == 0 {
+		return nil
+	}
+	return d.errs
+}
+
+// An assignFunc assigns a decoded value to v once its key has been followed to
+// the end.
+type assignFunc func(v reflect.Value) error
+
+// assignString returns an assignFunc that assigns the form value val.
+func assignString(d *decodeState, val string) assignFunc {
+	return func(v reflect.Value) error {
+		return setElem(v, val, d.field)
+	}
+}
+
+// unmarshalPath assigns a value to the value found by following segments from
+// v. The path is the key of v itself and is used to name nested keys.
+func unmarshalPath(d *decodeState, v reflect.Value, path string, segments []string, assign assignFunc) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
 		}
+		v = v.Elem()
+	}
+	if v.Type() == valuesType {
+		return unmarshalValuesField(d, v, path, segments, assign)
 	}
+	if len(segments) == 0 {
+		return unmarshalLeaf(d, v, path, assign)
+	}
+	if decodesItself(v) || v.Type() == fileType {
+		d.unknownKey()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return unmarshalStruct(d, v, path, segments, assign)
+	case reflect.Map:
+		return unmarshalMap(d, v, path, segments, assign)
+	case reflect.Slice, reflect.Array:
+		return unmarshalSlice(d, v, path, segments, assign)
+	}
+	d.unknownKey()
 	return nil
 }
 
-func unmarshalMap(data url.Values, v reflect.Value) error {
+// unmarshalLeaf assigns a value to v, which is addressed by path and has no
+// nested keys left to follow. A repeated key appends to a slice, just as empty
+// brackets do. For anything else the first value wins.
+func unmarshalLeaf(d *decodeState, v reflect.Value, path string, assign assignFunc) error {
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && !decodesItself(v) &&
+		!isByteSlice(v.Type()) && !d.field.formatsBytes(v.Type()) {
+		return unmarshalSlice(d, v, path, []string{""}, assign)
+	}
+	if d.seen[path] {
+		return nil
+	}
+	d.seen[path] = true
+
+	// The value does not know the key it was found under, so the key is
+	// filled in here, where the full path is known.
+	err := assign(v)
+	if typeErr, ok := err.(*UnmarshalTypeError); ok && typeErr.Field == "" {
+		typeErr.Field = path
+	}
+	return err
+}
+
+func unmarshalStruct(d *decodeState, v reflect.Value, path string, segments []string, assign assignFunc) error {
+	f, ok := cachedTypeFields(v.Type()).byName[segments[0]]
+	if !ok {
+		d.unknownKey()
+		return nil
+	}
+	// The tag of the field applies to every value below it, but not to those
+	// in the fields of a nested struct, which have their own.
+	outer := d.field
+	d.field = f
+	fieldPath := d.opts.keySyntax.nest(path, f.name)
+	err := unmarshalPath(d, v.Field(f.index), fieldPath, segments[1:], assign)
+	d.field = outer
+	return err
+}
+
+func unmarshalMap(d *decodeState, v reflect.Value, path string, segments []string, assign assignFunc) error {
 	if v.IsNil() {
 		v.Set(reflect.MakeMap(v.Type()))
 	}
 
-	if v.Type().Key().Kind() != reflect.String {
-		return fmt.Errorf("form: unsupported map key type: %v", v.Type().Key())
+	keyType := v.Type().Key()
+	if keyType.Kind() != reflect.String && !reflect.PointerTo(keyType).Implements(textUnmarshalerType) &&
+		!isScalarKey(keyType) {
+		return &UnsupportedTypeError{v.Type()}
 	}
 
+	// Only structs and maps can hold nested keys. For any other element type
+	// the remainder of the key is kept intact as the map key, so that a
+	// map[string]string still receives filter[name] verbatim. The one
+	// exception is a trailing index or empty brackets on a slice, as in
+	// tags[0] or tags[], which chooses the element of the slice.
 	elemType := v.Type().Elem()
-	for key, values := range data {
-		elemValue := reflect.New(elemType).Elem()
-		if err := set(elemValue, values); err != nil {
-			return fmt.Errorf("form: failed to set map value for key %s: %w", key, err)
+	key, rest := segments[0], segments[1:]
+	if !isNestable(elemType) {
+		n := len(segments)
+		if n > 1 && isSliceIndex(segments[n-1]) && holdsValues(elemType, d.field) {
+			key, rest = d.opts.keySyntax.join(segments[:n-1]), segments[n-1:]
+		} else {
+			key, rest = d.opts.keySyntax.join(segments), nil
 		}
+	}
 
-		v.SetMapIndex(reflect.ValueOf(key), elemValue)
+	// Map elements are not addressable, so the element is decoded into a copy
+	// and stored back. An element already assigned by this decode is carried
+	// over so that later keys add to it rather than replace it.
+	elemPath := d.opts.keySyntax.nest(path, key)
+	mapKey, err := resolveMapKey(keyType, key)
+	if err != nil {
+		return &UnmarshalTypeError{Field: elemPath, Value: key, Type: keyType, Err: fmt.Errorf("invalid map key: %w", err)}
+	}
+	elemValue := reflect.New(elemType).Elem()
+	if d.elems[elemPath] {
+		if cur := v.MapIndex(mapKey); cur.IsValid() {
+			elemValue.Set(cur)
+		}
+	}
+	if err := unmarshalPath(d, elemValue, elemPath, rest, assign); err != nil {
+		return err
+	}
+	if d.elems == nil {
+		d.elems = map[string]bool{}
 	}
+	d.elems[elemPath] = true
 
+	v.SetMapIndex(mapKey, elemValue)
 	return nil
 }
 
+// isSliceIndex reports whether the key segment s is an index or empty, as in
+// tags[0] or tags[].
+func isSliceIndex(s string) bool {
+	if s == "" {
+		return true
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0
+}
+
+// holdsValues reports whether t is a slice or array decoded from several
+// values, rather than one that decodes from a single value, such as a byte
+// slice or a type that unmarshals itself.
+func holdsValues(t reflect.Type, f *field) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(unmarshalerType) &&
+		!reflect.PointerTo(t).Implements(textUnmarshalerType) &&
+		!isByteSlice(t) && !f.formatsBytes(t)
+}
+
+// resolveMapKey returns the map key of type t named by key, or the reason it
+// could not be parsed. A type that unmarshals itself from text does so, as
+// with encoding/json, even if it is also a string. A scalar is parsed just as
+// a value of its type would be.
+func resolveMapKey(t reflect.Type, key string) (reflect.Value, error) {
+	kv := reflect.New(t)
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+	if err := setScalar(kv.Elem(), key); err != nil {
+		if typeErr, ok := err.(*UnmarshalTypeError); ok {
+			err = typeErr.Err
+		}
+		return reflect.Value{}, err
+	}
+	return kv.Elem(), nil
+}
+
+// unmarshalSlice assigns a value to an element of the slice or array v. The
+// element is chosen by an index, as in children[0][name], or appended for
+// empty brackets, as in tags[]. Indices may be sparse: the slice grows to fit
+// the largest one and the elements in between are left as zero values. An
+// array cannot grow, so an index beyond its length is an error.
+func unmarshalSlice(d *decodeState, v reflect.Value, path string, segments []string, assign assignFunc) error {
+	i := -1
+	if segments[0] != "" {
+		n, err := strconv.Atoi(segments[0])
+		if err != nil || n < 0 {
+			d.unknownKey()
+			return nil
+		}
+		i = n
+	}
+
+	if v.Kind() == reflect.Array {
+		return unmarshalArray(d, v, path, i, segments[1:], assign)
+	}
+
+	if !d.seen[path] {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 1))
+		d.seen[path] = true
+	}
+	if i > d.opts.maxSliceIndex {
+		return fmt.Errorf("form: slice index %d of %s exceeds maximum of %d", i, path, d.opts.maxSliceIndex)
+	}
+	if i < 0 {
+		i = v.Len()
+	}
+	if i >= v.Len() {
+		v.Grow(i + 1 - v.Len())
+		v.SetLen(i + 1)
+	}
+
+	elemPath := d.opts.keySyntax.nest(path, strconv.Itoa(i))
+	return unmarshalPath(d, v.Index(i), elemPath, segments[1:], assign)
+}
+
+// unmarshalArray is unmarshalSlice for arrays. Appending fills the element
+// after the last one assigned, which an array has no length to record, so it
+// is kept in d.lens.
+func unmarshalArray(d *decodeState, v reflect.Value, path string, i int, segments []string, assign assignFunc) error {
+	if d.lens == nil {
+		d.lens = map[string]int{}
+	}
+	if !d.seen[path] {
+		v.SetZero()
+		d.lens[path] = 0
+		d.seen[path] = true
+	}
+	if i < 0 {
+		i = d.lens[path]
+	}
+	if i >= v.Len() {
+		return fmt.Errorf("form: %s of type %s cannot hold more than %d values", path, v.Type(), v.Len())
+	}
+	d.lens[path] = max(d.lens[path], i+1)
+
+	elemPath := d.opts.keySyntax.nest(path, strconv.Itoa(i))
+	return unmarshalPath(d, v.Index(i), elemPath, segments, assign)
+}
+
+// isNestable reports whether values of type t are built from nested keys.
+func isNestable(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == fileType || reflect.PointerTo(t).Implements(unmarshalerType) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Slice, reflect.Array:
+		return isNestable(t.Elem())
+	}
+	return false
+}
+
+var (
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
 func assertUnmarshaler(v reflect.Value) (Unmarshaler, bool) {
 	if v.CanAddr() {
 		if u, ok := v.Addr().Interface().(Unmarshaler); ok {
@@ -175,6 +608,30 @@ func assertUnmarshaler(v reflect.Value) (Unmarshaler, bool) {
 	return nil, false
 }
 
+// assertTextUnmarshaler is like assertUnmarshaler, for types that unmarshal
+// themselves from text.
+func assertTextUnmarshaler(v reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u, true
+		}
+	}
+	if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		return u, true
+	}
+	return nil, false
+}
+
+// decodesItself reports whether v is decoded from a single value by its own
+// UnmarshalForm or UnmarshalText method.
+func decodesItself(v reflect.Value) bool {
+	if _, ok := assertUnmarshaler(v); ok {
+		return true
+	}
+	_, ok := assertTextUnmarshaler(v)
+	return ok
+}
+
 func set(fv reflect.Value, val []string) error {
 	if fv.Kind() == reflect.Ptr {
 		if fv.IsNil() {
@@ -182,16 +639,32 @@ func set(fv reflect.Value, val []string) error {
 		}
 		fv = fv.Elem()
 	}
-	if fv.Kind() == reflect.Slice {
+	switch {
+	case isByteSlice(fv.Type()):
+		// A byte slice is a single value, like a string.
+	case fv.Kind() == reflect.Slice:
 		return setSlice(fv, val)
+	case fv.Kind() == reflect.Array:
+		return setArray(fv, val)
 	}
 	if len(val) == 0 {
 		return nil
 	}
-	if u, ok := assertUnmarshaler(fv); ok {
-		return u.UnmarshalForm([]byte(val[0]))
+	return setElem(fv, val[0], nil)
+}
+
+func setArray(fv reflect.Value, val []string) error {
+	if len(val) > fv.Len() {
+		return fmt.Errorf("form: %d values cannot be held by %s", len(val), fv.Type())
+	}
+
+	fv.SetZero()
+	for i, v := range val {
+		if err := setElem(fv.Index(i), v, nil); err != nil {
+			return err
+		}
 	}
-	return setScalar(fv, val[0])
+	return nil
 }
 
 func setSlice(fv reflect.Value, val []string) error {
@@ -200,20 +673,44 @@ func setSlice(fv reflect.Value, val []string) error {
 	}
 
 	for i, v := range val {
-		elem := fv.Index(i)
-		if u, ok := assertUnmarshaler(elem); ok {
-			if err := u.UnmarshalForm([]byte(v)); err != nil {
-				return fmt.Errorf("failed to set slice element %d: %w", i, err)
-			}
-			continue
-		}
-		if err := setScalar(elem, v); err != nil {
-			return fmt.Errorf("failed to set slice element %d: %w", i, err)
+		if err := setElem(fv.Index(i), v, nil); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// setElem assigns val to elem. The tag of f, the struct field elem belongs
+// to if any, may set the format of val.
+func setElem(elem reflect.Value, val string, f *field) error {
+	if u, ok := assertUnmarshaler(elem); ok {
+		if err := u.UnmarshalForm([]byte(val)); err != nil {
+			return &UnmarshalTypeError{Value: val, Type: elem.Type(), Err: err}
+		}
+		return nil
+	}
+	switch {
+	case f.formatsTime(elem.Type()):
+		return f.time.set(elem, val)
+	case f.formatsBytes(elem.Type()):
+		return f.bytes.set(elem, val)
+	case elem.Type() == timeType:
+		return defaultTimeFormat.set(elem, val)
+	case elem.Type() == durationType:
+		return setDuration(elem, val)
+	}
+	if u, ok := assertTextUnmarshaler(elem); ok {
+		if err := u.UnmarshalText([]byte(val)); err != nil {
+			return &UnmarshalTypeError{Value: val, Type: elem.Type(), Err: err}
+		}
+		return nil
+	}
+	if isByteSlice(elem.Type()) {
+		return base64Bytes.set(elem, val)
+	}
+	return setScalar(elem, val)
+}
+
 func setScalar(v reflect.Value, val string) error {
 	switch v.Kind() {
 	case reflect.String:
@@ -221,31 +718,62 @@ func setScalar(v reflect.Value, val string) error {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		i, err := parseInt(val, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("setScalar: %w", err)
+			return typeError(v, val, err)
 		}
 		v.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		i, err := parseUint(val, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("setScalar: %w", err)
+			return typeError(v, val, err)
 		}
 		v.SetUint(i)
 	case reflect.Float32, reflect.Float64:
 		f, err := parseFloat(val, v.Type().Bits())
 		if err != nil {
-			return fmt.Errorf("setScalar: %w", err)
+			return typeError(v, val, err)
 		}
 		v.SetFloat(f)
 	case reflect.Bool:
 		b, err := parseBool(val)
 		if err != nil {
-			return fmt.Errorf("setScalar: %w", err)
+			return typeError(v, val, err)
 		}
 		v.SetBool(b)
+	case reflect.Interface:
+		// Only an empty interface can hold the raw string; there is no way
+		// to know which concrete type any other interface expects.
+		if v.NumMethod() != 0 {
+			return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: errNotScalar}
+		}
+		v.Set(reflect.ValueOf(val))
+	case reflect.Struct, reflect.Map:
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: errNestedKeys}
+	default:
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: errNotScalar}
 	}
 	return nil
 }
 
+var (
+	// errNestedKeys is reported when a single value is sent for a struct or
+	// map, which can only be decoded from nested keys.
+	errNestedKeys = errors.New("expected nested keys")
+
+	// errNotScalar is reported when a single value is sent for a type that
+	// cannot hold one, such as a func or a non-empty interface.
+	errNotScalar = errors.New("type cannot hold a single value")
+)
+
+// typeError returns an [UnmarshalTypeError] for val, which could not be parsed
+// into v. The error of a failed strconv call is reduced to its reason, since
+// the error already records the value and type.
+func typeError(v reflect.Value, val string, err error) error {
+	if numErr, ok := err.(*strconv.NumError); ok {
+		err = numErr.Err
+	}
+	return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: err}
+}
+
 func parseInt(s string, bitSize int) (int64, error) {
 	return strconv.ParseInt(s, 10, bitSize)
 }
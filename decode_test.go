@@ -1,7 +1,10 @@
 package encoding_test
 
 import (
+	"errors"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -214,6 +217,46 @@ func TestUnmarshal_CompositeTypes(t *testing.T) {
 			target: new([]int),
 			want:   &[]int{},
 		},
+		{
+			name:   "array of ints",
+			input:  []byte("1&2"),
+			target: &[3]int{7, 8, 9},
+			want:   &[3]int{1, 2, 0},
+		},
+		{
+			name:    "array with too many values",
+			input:   []byte("1&2&3&4"),
+			target:  new([3]int),
+			wantErr: true,
+		},
+		{
+			name:   "struct with arrays",
+			input:  []byte("scores=1&scores=2&pair[1][sku]=b&pair[0][qty]=1&raw=7&raw=8"),
+			target: &ArrayForm{Scores: [3]int{7, 8, 9}},
+			want: &ArrayForm{
+				Scores: [3]int{1, 2, 0},
+				Pair:   [2]Item{{Qty: 1}, {SKU: "b"}},
+				Raw:    [2]byte{7, 8},
+			},
+		},
+		{
+			name:   "array with empty brackets",
+			input:  []byte("scores[]=1&scores[2]=3"),
+			target: &ArrayForm{},
+			want:   &ArrayForm{Scores: [3]int{1, 0, 3}},
+		},
+		{
+			name:    "array with too many repeated keys",
+			input:   []byte("scores=1&scores=2&scores=3&scores=4"),
+			target:  &ArrayForm{},
+			wantErr: true,
+		},
+		{
+			name:    "array index out of range",
+			input:   []byte("pair[2][sku]=c"),
+			target:  &ArrayForm{},
+			wantErr: true,
+		},
 		{
 			name:   "map with string keys and int values",
 			input:  []byte("a=1&b=2&c=3"),
@@ -227,11 +270,41 @@ func TestUnmarshal_CompositeTypes(t *testing.T) {
 			want:   &map[string]string{"a": "x", "b": "y", "c": "z"},
 		},
 		{
-			name:    "map with non-string key",
-			input:   []byte("1=1&2=2&3=3"),
+			name:   "map with int keys",
+			input:  []byte("1=1&2=2&3=3"),
+			target: new(map[int]int),
+			want:   &map[int]int{1: 1, 2: 2, 3: 3},
+		},
+		{
+			name:   "map with typed string keys",
+			input:  []byte("a=1&b=2"),
+			target: new(map[Code]int),
+			want:   &map[Code]int{"a": 1, "b": 2},
+		},
+		{
+			name:   "map with bool and float keys",
+			input:  []byte("true[1.5]=x"),
+			target: new(map[bool]map[float64]string),
+			want:   &map[bool]map[float64]string{true: {1.5: "x"}},
+		},
+		{
+			name:    "map with invalid int key",
+			input:   []byte("1=1&two=2"),
 			target:  new(map[int]int),
 			wantErr: true,
 		},
+		{
+			name:    "map with uint key out of range",
+			input:   []byte("256=1"),
+			target:  new(map[uint8]int),
+			wantErr: true,
+		},
+		{
+			name:    "map with struct keys",
+			input:   []byte("a=1"),
+			target:  new(map[Address]int),
+			wantErr: true,
+		},
 		{
 			name:   "empty map",
 			input:  []byte(""),
@@ -320,6 +393,121 @@ func TestUnmarshal_CompositeTypes(t *testing.T) {
 			target:  &ComplexForm{},
 			wantErr: true,
 		},
+		{
+			name: "nested struct",
+			input: valuesToBytes(url.Values{
+				"address[city]":    {"London"},
+				"address[zip]":     {"N1 9GU"},
+				"billing[city]":    {"Leeds"},
+				"metadata[source]": {"web"},
+				"name":             {"john"},
+			}),
+			target: &NestedForm{},
+			want: &NestedForm{
+				Name:    "john",
+				Address: Address{City: "London", Zip: "N1 9GU"},
+				Billing: &Address{City: "Leeds"},
+				Metadata: map[string]string{
+					"source": "web",
+				},
+			},
+		},
+		{
+			name: "nested map",
+			input: valuesToBytes(url.Values{
+				"a[x]": {"1"},
+				"a[y]": {"2"},
+				"b[z]": {"3"},
+			}),
+			target: new(map[string]map[string]int),
+			want: &map[string]map[string]int{
+				"a": {"x": 1, "y": 2},
+				"b": {"z": 3},
+			},
+		},
+		{
+			name: "bracketed keys in flat map",
+			input: valuesToBytes(url.Values{
+				"filter[name]": {"john"},
+			}),
+			target: new(map[string]string),
+			want:   &map[string]string{"filter[name]": "john"},
+		},
+		{
+			name: "invalid nested value",
+			input: valuesToBytes(url.Values{
+				"a[x]": {"not_a_number"},
+			}),
+			target:  new(map[string]map[string]int),
+			wantErr: true,
+		},
+		{
+			name:   "indexed slice of structs",
+			input:  []byte("items[0][sku]=x1&items[0][qty]=1&items[1][sku]=x2"),
+			target: &OrderForm{},
+			want: &OrderForm{
+				Items: []Item{
+					{SKU: "x1", Qty: 1},
+					{SKU: "x2"},
+				},
+			},
+		},
+		{
+			name:   "sparse slice indices",
+			input:  []byte("items[2][sku]=x3&items[0][sku]=x1"),
+			target: &OrderForm{},
+			want: &OrderForm{
+				Items: []Item{{SKU: "x1"}, {}, {SKU: "x3"}},
+			},
+		},
+		{
+			name:   "empty bracket slice",
+			input:  []byte("tags[]=a&tags[]=b"),
+			target: &OrderForm{},
+			want:   &OrderForm{Tags: []string{"a", "b"}},
+		},
+		{
+			name:   "indexed scalar slice",
+			input:  []byte("tags[1]=b&tags[0]=a"),
+			target: &OrderForm{},
+			want:   &OrderForm{Tags: []string{"a", "b"}},
+		},
+		{
+			name:   "slice replaces existing elements",
+			input:  []byte("tags=c"),
+			target: &OrderForm{Tags: []string{"a", "b"}},
+			want:   &OrderForm{Tags: []string{"c"}},
+		},
+		{
+			name:    "slice index exceeds maximum",
+			input:   []byte("items[1001][sku]=x"),
+			target:  &OrderForm{},
+			wantErr: true,
+		},
+		{
+			name:   "negative slice index is ignored",
+			input:  []byte("items[-1][sku]=x"),
+			target: &OrderForm{},
+			want:   &OrderForm{},
+		},
+		{
+			name:   "map of empty interface",
+			input:  []byte("a=1&b=two"),
+			target: new(map[string]any),
+			want:   &map[string]any{"a": "1", "b": "two"},
+		},
+		{
+			name:    "chan",
+			input:   []byte("1"),
+			target:  new(chan int),
+			wantErr: true,
+		},
+		{
+			name:    "map of complex",
+			input:   []byte("a=1"),
+			target:  new(map[string]complex128),
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -339,6 +527,507 @@ func TestUnmarshal_CompositeTypes(t *testing.T) {
 	}
 }
 
+func TestUnmarshal_Options(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   []byte
+		opts    []encoding.Option
+		target  any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:   "slice index within maximum",
+			input:  []byte("tags[2]=c"),
+			opts:   []encoding.Option{encoding.WithMaxSliceIndex(2)},
+			target: &OrderForm{},
+			want:   &OrderForm{Tags: []string{"", "", "c"}},
+		},
+		{
+			name:    "slice index exceeds maximum",
+			input:   []byte("tags[3]=d"),
+			opts:    []encoding.Option{encoding.WithMaxSliceIndex(2)},
+			target:  &OrderForm{},
+			wantErr: true,
+		},
+		{
+			name:   "appended elements beyond maximum",
+			input:  []byte("tags[]=a&tags[]=b&tags=c"),
+			opts:   []encoding.Option{encoding.WithMaxSliceIndex(1)},
+			target: &OrderForm{},
+			want:   &OrderForm{Tags: []string{"a", "b", "c"}},
+		},
+		{
+			name:   "repeated keys beyond default maximum",
+			input:  []byte(strings.Repeat("tags=a&", 1001) + "tags=b"),
+			target: &OrderForm{},
+			want:   &OrderForm{Tags: append(slices.Repeat([]string{"a"}, 1001), "b")},
+		},
+		{
+			name:   "dot syntax",
+			input:  []byte("name=john&address.city=London&billing.zip=LS1"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: &NestedForm{},
+			want: &NestedForm{
+				Name:    "john",
+				Address: Address{City: "London"},
+				Billing: &Address{Zip: "LS1"},
+			},
+		},
+		{
+			name:   "dot syntax with indices",
+			input:  []byte("items.0.sku=x1&items.1.sku=x2&tags=a&tags=b"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: &OrderForm{},
+			want: &OrderForm{
+				Tags:  []string{"a", "b"},
+				Items: []Item{{SKU: "x1"}, {SKU: "x2"}},
+			},
+		},
+		{
+			name:   "dot syntax with bracketed indices",
+			input:  []byte("items[0].sku=x1&items[1].qty=2"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: &OrderForm{},
+			want: &OrderForm{
+				Items: []Item{{SKU: "x1"}, {Qty: 2}},
+			},
+		},
+		{
+			name:   "dot syntax accepts bracketed keys",
+			input:  []byte("address[city]=London"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: &NestedForm{},
+			want:   &NestedForm{Address: Address{City: "London"}},
+		},
+		{
+			name:   "bracket syntax ignores dotted keys",
+			input:  []byte("address.city=London"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.BracketSyntax)},
+			target: &NestedForm{},
+			want:   &NestedForm{},
+		},
+		{
+			name:   "dot syntax in flat map",
+			input:  []byte("filter.name=john"),
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: new(map[string]string),
+			want:   &map[string]string{"filter.name": "john"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, tt.target, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, tt.target); diff != "" {
+					t.Errorf("Unmarshal() mismatch %s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSliceStyle_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	type GroupsForm struct {
+		Groups map[string][]string `form:"groups"`
+		Scores map[string][2]int   `form:"scores"`
+	}
+	want := GroupsForm{
+		Groups: map[string][]string{"admins": {"ann", "bob"}, "users": {"cat"}},
+		Scores: map[string][2]int{"ann": {1, 2}},
+	}
+
+	styles := map[string]encoding.SliceStyle{
+		"repeated keys":  encoding.RepeatedKeys,
+		"empty brackets": encoding.EmptyBrackets,
+		"indexed keys":   encoding.IndexedKeys,
+	}
+	syntaxes := map[string]encoding.KeySyntax{
+		"bracket syntax": encoding.BracketSyntax,
+		"dot syntax":     encoding.DotSyntax,
+	}
+	for styleName, style := range styles {
+		for syntaxName, syntax := range syntaxes {
+			t.Run(styleName+" with "+syntaxName, func(t *testing.T) {
+				t.Parallel()
+
+				opts := []encoding.Option{encoding.WithSliceStyle(style), encoding.WithKeySyntax(syntax)}
+				b, err := encoding.Marshal(want, opts...)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				var got GroupsForm
+				if err := encoding.Unmarshal(b, &got, opts...); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if diff := diff(want, got); diff != "" {
+					t.Errorf("round trip of %s mismatch %s", b, diff)
+				}
+			})
+		}
+	}
+}
+
+func TestUnmarshal_TextUnmarshaler(t *testing.T) {
+	t.Parallel()
+
+	addr := netip.MustParseAddr("10.0.0.1")
+	gateway := netip.MustParseAddr("10.0.0.254")
+
+	tests := []struct {
+		name    string
+		input   []byte
+		target  any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:   "struct fields",
+			input:  []byte("addr=10.0.0.1&gateway=10.0.0.254&level=high"),
+			target: &NetworkForm{},
+			want:   &NetworkForm{Addr: addr, Gateway: &gateway, Level: LevelHigh},
+		},
+		{
+			name:   "slice elements",
+			input:  []byte("peers=10.0.0.1&peers=10.0.0.254"),
+			target: &NetworkForm{},
+			want:   &NetworkForm{Peers: []netip.Addr{addr, gateway}},
+		},
+		{
+			name:   "map values",
+			input:  []byte("a=low&b=high"),
+			target: new(map[string]Level),
+			want:   &map[string]Level{"a": LevelLow, "b": LevelHigh},
+		},
+		{
+			name:   "map keys",
+			input:  []byte("limits[high]=10&limits[low]=5"),
+			target: &NetworkForm{},
+			want:   &NetworkForm{Limits: map[Level]int{LevelHigh: 10, LevelLow: 5}},
+		},
+		{
+			name:   "unmarshaler takes precedence",
+			input:  []byte("version=v1"),
+			target: &NetworkForm{},
+			want:   &NetworkForm{Version: "form-v1"},
+		},
+		{
+			name:   "top-level value",
+			input:  []byte("10.0.0.1"),
+			target: &netip.Addr{},
+			want:   &addr,
+		},
+		{
+			name:    "unmarshal error",
+			input:   []byte("level=medium"),
+			target:  &NetworkForm{},
+			wantErr: true,
+		},
+		{
+			name:    "map key error",
+			input:   []byte("limits[medium]=1"),
+			target:  &NetworkForm{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				var typeErr *encoding.UnmarshalTypeError
+				if !errors.As(err, &typeErr) || !errors.Is(err, errInvalidLevel) {
+					t.Errorf("Unmarshal() error = %v, want *UnmarshalTypeError wrapping %v", err, errInvalidLevel)
+				}
+				return
+			}
+			if diff := diff(tt.want, tt.target); diff != "" {
+				t.Errorf("Unmarshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_TypeError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     []byte
+		target    any
+		wantField string
+		wantValue string
+		wantType  string
+	}{
+		{
+			name:      "struct field",
+			input:     []byte("name=john&age=twenty"),
+			target:    &BasicForm{},
+			wantField: "age",
+			wantValue: "twenty",
+			wantType:  "int",
+		},
+		{
+			name:      "nested struct field",
+			input:     []byte("items[1][qty]=many&items[0][qty]=1"),
+			target:    &OrderForm{},
+			wantField: "items[1][qty]",
+			wantValue: "many",
+			wantType:  "int",
+		},
+		{
+			name:      "map element",
+			input:     []byte("a=1&b=x"),
+			target:    new(map[string]int),
+			wantField: "b",
+			wantValue: "x",
+			wantType:  "int",
+		},
+		{
+			name:      "map key",
+			input:     []byte("limits[1]=1&limits[two]=2"),
+			target:    new(map[string]map[int]int),
+			wantField: "limits[two]",
+			wantValue: "two",
+			wantType:  "int",
+		},
+		{
+			name:      "unmarshaler",
+			input:     []byte("created_at=yesterday"),
+			target:    &ComplexForm{},
+			wantField: "created_at",
+			wantValue: "yesterday",
+			wantType:  "encoding_test.MyDate",
+		},
+		{
+			name:      "value for nested struct",
+			input:     []byte("name=john&address=London"),
+			target:    &NestedForm{},
+			wantField: "address",
+			wantValue: "London",
+			wantType:  "encoding_test.Address",
+		},
+		{
+			name:      "value for map",
+			input:     []byte("labels=x"),
+			target:    new(map[string]map[string]string),
+			wantField: "labels",
+			wantValue: "x",
+			wantType:  "map[string]string",
+		},
+		{
+			name:      "primitive slice",
+			input:     []byte("1&2&x"),
+			target:    new([]int),
+			wantValue: "x",
+			wantType:  "int",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, tt.target)
+			var typeErr *encoding.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("Unmarshal() error = %v, want *UnmarshalTypeError", err)
+			}
+			if typeErr.Field != tt.wantField {
+				t.Errorf("UnmarshalTypeError.Field = %q, want %q", typeErr.Field, tt.wantField)
+			}
+			if typeErr.Value != tt.wantValue {
+				t.Errorf("UnmarshalTypeError.Value = %q, want %q", typeErr.Value, tt.wantValue)
+			}
+			if got := typeErr.Type.String(); got != tt.wantType {
+				t.Errorf("UnmarshalTypeError.Type = %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_AllErrors(t *testing.T) {
+	t.Parallel()
+
+	input := []byte("id=x&name=jane&age=y&created_at=z")
+
+	t.Run("first error", func(t *testing.T) {
+		t.Parallel()
+
+		err := encoding.Unmarshal(input, &ComplexForm{})
+		var fieldErrs encoding.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			t.Fatalf("Unmarshal() error = %v, want a single error", err)
+		}
+		var typeErr *encoding.UnmarshalTypeError
+		if !errors.As(err, &typeErr) || typeErr.Field != "id" {
+			t.Errorf("Unmarshal() error = %v, want *UnmarshalTypeError for id", err)
+		}
+	})
+
+	t.Run("all errors", func(t *testing.T) {
+		t.Parallel()
+
+		got := &ComplexForm{}
+		err := encoding.Unmarshal(input, got, encoding.WithAllErrors())
+		var fieldErrs encoding.FieldErrors
+		if !errors.As(err, &fieldErrs) {
+			t.Fatalf("Unmarshal() error = %v, want FieldErrors", err)
+		}
+
+		var fields []string
+		for _, err := range fieldErrs {
+			var typeErr *encoding.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("FieldErrors entry = %v, want *UnmarshalTypeError", err)
+			}
+			fields = append(fields, typeErr.Field)
+		}
+		if diff := diff([]string{"id", "age", "created_at"}, fields); diff != "" {
+			t.Errorf("FieldErrors fields mismatch %s", diff)
+		}
+		if got.Name != "jane" {
+			t.Errorf("Unmarshal() Name = %q, want %q", got.Name, "jane")
+		}
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		t.Parallel()
+
+		err := encoding.Unmarshal([]byte("id=1"), &ComplexForm{}, encoding.WithAllErrors())
+		if err != nil {
+			t.Errorf("Unmarshal() error = %v, want nil", err)
+		}
+	})
+}
+
+func TestUnmarshalValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   url.Values
+		target  any
+		opts    []encoding.Option
+		want    any
+		wantErr bool
+	}{
+		{
+			name:   "struct",
+			input:  url.Values{"name": {"john"}, "aliases": {"johnny", "jon"}, "age": {"30"}},
+			target: &BasicForm{},
+			want:   &BasicForm{Name: "john", Aliases: []string{"johnny", "jon"}, Age: 30},
+		},
+		{
+			name:   "nested struct",
+			input:  url.Values{"name": {"john"}, "address[city]": {"London"}, "metadata[a]": {"1"}},
+			target: &NestedForm{},
+			want: &NestedForm{
+				Name:     "john",
+				Address:  Address{City: "London"},
+				Metadata: map[string]string{"a": "1"},
+			},
+		},
+		{
+			name:   "map",
+			input:  url.Values{"a": {"1"}, "b": {"2"}},
+			target: new(map[string]int),
+			want:   &map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:   "unmarshaler",
+			input:  url.Values{"a": {"1"}, "b": {"2", "3"}},
+			target: &encoding.Values{},
+			want:   &encoding.Values{{"a", "1"}, {"b", "2"}, {"b", "3"}},
+		},
+		{
+			name:   "dot syntax",
+			input:  url.Values{"address.city": {"London"}},
+			target: &NestedForm{},
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			want:   &NestedForm{Address: Address{City: "London"}},
+		},
+		{
+			name:    "type error",
+			input:   url.Values{"age": {"thirty"}},
+			target:  &BasicForm{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			input:   url.Values{"name": {"john"}, "email": {"john@example.com"}},
+			target:  &BasicForm{},
+			opts:    []encoding.Option{encoding.WithDisallowUnknownFields()},
+			wantErr: true,
+		},
+		{
+			name:    "primitive",
+			input:   url.Values{"a": {"1"}},
+			target:  new(int),
+			wantErr: true,
+		},
+		{
+			name:    "non-pointer",
+			input:   url.Values{"a": {"1"}},
+			target:  BasicForm{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.UnmarshalValues(tt.input, tt.target, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalValues() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, tt.target); diff != "" {
+					t.Errorf("UnmarshalValues() mismatch %s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalValues_Errors(t *testing.T) {
+	t.Parallel()
+
+	input := url.Values{"id": {"x"}, "name": {"jane"}, "age": {"y"}}
+	err := encoding.UnmarshalValues(input, &ComplexForm{}, encoding.WithAllErrors())
+	var fieldErrs encoding.FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("UnmarshalValues() error = %v, want FieldErrors", err)
+	}
+
+	var fields []string
+	for _, err := range fieldErrs {
+		var typeErr *encoding.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("FieldErrors entry = %v, want *UnmarshalTypeError", err)
+		}
+		fields = append(fields, typeErr.Field)
+	}
+	if diff := diff([]string{"age", "id"}, fields); diff != "" {
+		t.Errorf("FieldErrors fields mismatch %s", diff)
+	}
+}
+
 func BenchmarkUnmarshal(b *testing.B) {
 	benchmarks := []struct {
 		name   string
@@ -425,6 +1114,32 @@ func BenchmarkUnmarshal(b *testing.B) {
 			}),
 			target: &ComplexForm{},
 		},
+		{
+			name: "nested form",
+			input: valuesToBytes(url.Values{
+				"address[city]":    {"London"},
+				"address[zip]":     {"E1 6AN"},
+				"billing[city]":    {"Leeds"},
+				"billing[zip]":     {"LS1 4AP"},
+				"metadata[ref]":    {"abc"},
+				"metadata[source]": {"web"},
+				"name":             {"jane"},
+			}),
+			target: &NestedForm{},
+		},
+		{
+			name: "slice of structs",
+			input: valuesToBytes(url.Values{
+				"items[0][qty]": {"2"},
+				"items[0][sku]": {"A1"},
+				"items[1][qty]": {"1"},
+				"items[1][sku]": {"B2"},
+				"items[2][qty]": {"5"},
+				"items[2][sku]": {"C3"},
+				"tags":          {"gift", "express"},
+			}),
+			target: &OrderForm{},
+		},
 	}
 	for _, bm := range benchmarks {
 		b.Run(bm.name, func(b *testing.B) {
@@ -1,11 +1,18 @@
 package encoding
 
 import (
+	"cmp"
+	"encoding"
 	"fmt"
+	"io"
+	"maps"
+	"net/textproto"
 	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 )
 
 // Marshaler is the interface implemented by types that can marshal themselves
@@ -14,205 +21,873 @@ type Marshaler interface {
 	MarshalForm() ([]byte, error)
 }
 
+// A MarshalerError describes an error returned by the MarshalForm or
+// MarshalText method of a field, or of a map key. Field is the key the value
+// would have been written under, such as user[address][zip].
+type MarshalerError struct {
+	Field      string
+	Type       reflect.Type
+	Err        error
+	sourceFunc string
+}
+
+func (e *MarshalerError) Error() string {
+	srcFunc := e.sourceFunc
+	if srcFunc == "" {
+		srcFunc = "MarshalForm"
+	}
+	return "form: error calling " + srcFunc + " for field " + e.Field + " of type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalerError) Unwrap() error { return e.Err }
+
+// An UnsupportedTypeError is returned by [Marshal] when attempting to encode an
+// unsupported value type.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "form: unsupported type: " + e.Type.String()
+}
+
+// An UnsupportedValueError is returned by [Marshal] when attempting to encode an
+// unsupported value, such as one that refers back to itself.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "form: unsupported value: " + e.Str
+}
+
 // Marshal returns the form encoding of v.
-func Marshal(v any) ([]byte, error) {
-	if m, ok := v.(Marshaler); ok {
-		return marshalForm(m)
+//
+// Struct fields are encoded in declaration order and map entries in key order,
+// or the order set with [WithMapKeyOrder]. With [WithSortKeys] every pair is
+// sorted by key instead, as [url.Values.Encode] does.
+//
+// A value that implements [Marshaler] is written as the result of its
+// MarshalForm method. Otherwise, one that implements [encoding.TextMarshaler]
+// is written as a single value, the result of its MarshalText method. Methods
+// with pointer receivers are used even on a value that cannot be addressed,
+// which is copied to call them. Map keys must be strings, booleans, numbers or
+// implement encoding.TextMarshaler, and are formatted as values of their type
+// would be.
+//
+// A [time.Time] is written in RFC 3339 format, unless the tag of its field sets
+// another: layout=2006-01-02 for a layout, or unix or unixmilli for the number
+// of seconds or milliseconds since the Unix epoch. The tz option, as in
+// tz=Europe/London, sets the time zone it is written in, which is otherwise
+// UTC for a layout. The zero time is written as an empty value, and is empty
+// for omitempty. A [time.Duration] is written as by its String method.
+//
+// A byte slice is written as a single value in padded base64, or in the
+// encoding set by the hex, base64 or base64url option in the tag of its field.
+// base64url is written without padding. Arrays are written like slices, and so
+// is a byte array unless its field sets one of those options.
+//
+// Channel, complex and function values cannot be encoded in a form. Attempting
+// to encode such a value causes Marshal to return an [UnsupportedTypeError].
+// Form cannot represent cyclic data structures and Marshal does not handle
+// them; passing cyclic structures will result in an [UnsupportedValueError].
+func Marshal(v any, opts ...Option) ([]byte, error) {
+	b, err := AppendMarshal(nil, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return []byte{}, nil
+	}
+	return b, nil
+}
+
+// AppendMarshal appends the form encoding of v to dst and returns the extended
+// buffer. It is like [Marshal], but reusing dst lets a caller that encodes
+// many values avoid allocating a new buffer for each. No separator is written
+// between the existing contents of dst and the first pair.
+func AppendMarshal(dst []byte, v any, opts ...Option) ([]byte, error) {
+	e := newEncodeState(dst, opts)
+	defer freeEncodeState(e)
+
+	if m, ok := asMarshaler(v); ok {
+		if err := marshalForm(e, m); err != nil {
+			return nil, err
+		}
+		return e.buf.buf, nil
 	}
 
 	rv := reflect.ValueOf(v)
 	if !rv.IsValid() {
-		return []byte{}, nil
+		return dst, nil
 	}
 
-	return marshal(rv)
+	return marshal(e, rv)
 }
 
-func marshal(v reflect.Value) ([]byte, error) {
+// asMarshaler returns v as a Marshaler. A value that is not one itself, but
+// whose pointer is, is copied so that the method can be called on it just as
+// it would be on an addressable field.
+func asMarshaler(v any) (Marshaler, bool) {
+	if m, ok := v.(Marshaler); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer || !reflect.PointerTo(rv.Type()).Implements(marshalerType) {
+		return nil, false
+	}
+	return addressable(rv).Interface().(Marshaler), true
+}
+
+// MarshalValues returns the form encoding of v as [url.Values], for use where
+// the pairs are wanted rather than an encoded body, such as when building the
+// query of a [url.URL]. v must be a struct, a map, a pointer to either, or a
+// [Marshaler]. Values are encoded as they are by [Marshal], and the same errors
+// are returned. The order of the pairs is not kept by url.Values, so
+// [WithSortKeys] and [WithMapKeyOrder] have no effect.
+func MarshalValues(v any, opts ...Option) (url.Values, error) {
+	data := url.Values{}
+	e := &encodeState{w: valuesWriter(data), opts: newOptions(opts)}
+
+	if m, ok := asMarshaler(v); ok {
+		if err := marshalForm(e, m); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer {
+		return data, nil
+	}
+	if !writesNestedKeys(rv.Type()) {
+		return nil, fmt.Errorf("form: cannot encode %v as url.Values", reflect.TypeOf(v))
+	}
+
+	if err := typeEncoder(rv.Type())(e, "", rv); err != nil {
+		return nil, fmt.Errorf("form: failed to marshal: %w", err)
+	}
+	return data, nil
+}
+
+// encodeState holds the state of a single call to [Marshal]: the options in
+// effect and the destination of the encoded pairs.
+type encodeState struct {
+	w    pairWriter
+	opts options
+
+	// buf is the destination of Marshal itself, kept here so that a pooled
+	// encodeState brings its writer with it.
+	buf bufferWriter
+
+	// Keep track of what pointers we've seen in the current recursive call
+	// path, to avoid cycles that could lead to a stack overflow. Only do the
+	// relatively expensive map operations if ptrLevel is larger than
+	// startDetectingCyclesAfter, so that we skip the work if we're within a
+	// reasonable amount of nested pointers deep.
+	ptrLevel uint
+	ptrSeen  map[any]struct{}
+}
+
+const startDetectingCyclesAfter = 1000
+
+var encodeStatePool sync.Pool
+
+// newEncodeState returns an encodeState, from the pool if there is one, that
+// appends to dst.
+func newEncodeState(dst []byte, opts []Option) *encodeState {
+	e, _ := encodeStatePool.Get().(*encodeState)
+	if e == nil {
+		e = new(encodeState)
+	}
+	e.opts.init(opts)
+	e.buf = bufferWriter{buf: dst}
+	e.w = &e.buf
+	e.ptrLevel = 0
+	if len(e.ptrSeen) > 0 {
+		clear(e.ptrSeen)
+	}
+	return e
+}
+
+// freeEncodeState returns e to the pool, dropping its references to the
+// caller's buffer.
+func freeEncodeState(e *encodeState) {
+	e.w = nil
+	e.buf = bufferWriter{}
+	encodeStatePool.Put(e)
+}
+
+// enterPointer records that the pointer, map or slice v is being encoded. It
+// returns an [UnsupportedValueError] if v is already being encoded further up
+// the call path. Every successful call must be paired with a call to
+// leavePointer.
+func (e *encodeState) enterPointer(v reflect.Value) error {
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		// We're a large number of nested pointers, maps or slices deep;
+		// start checking if we've run into a pointer cycle.
+		ptr := cycleKey(v)
+		if _, ok := e.ptrSeen[ptr]; ok {
+			e.ptrLevel--
+			return &UnsupportedValueError{v, fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		if e.ptrSeen == nil {
+			e.ptrSeen = make(map[any]struct{})
+		}
+		e.ptrSeen[ptr] = struct{}{}
+	}
+	return nil
+}
+
+func (e *encodeState) leavePointer(v reflect.Value) {
+	if e.ptrLevel > startDetectingCyclesAfter {
+		delete(e.ptrSeen, cycleKey(v))
+	}
+	e.ptrLevel--
+}
+
+// cycleKey returns the key v is recorded under while it is being encoded. A
+// slice is keyed on its length as well as its data pointer, since slices of
+// different lengths may share an array without forming a cycle.
+func cycleKey(v reflect.Value) any {
+	if v.Kind() == reflect.Slice {
+		return struct {
+			ptr any
+			len int
+		}{v.UnsafePointer(), v.Len()}
+	}
+	return v.UnsafePointer()
+}
+
+// A pairWriter receives the key-value pairs produced by encoding a value.
+type pairWriter interface {
+	writePair(key, value string) error
+}
+
+// A fileWriter is a pairWriter that can also receive file content. Only
+// destinations that implement it can encode [File] and [io.Reader] values.
+type fileWriter interface {
+	writeFile(key, filename string, header textproto.MIMEHeader, r io.Reader) error
+}
+
+// valuesWriter collects pairs into url.Values.
+type valuesWriter url.Values
+
+func (w valuesWriter) writePair(key, value string) error {
+	url.Values(w).Add(key, value)
+	return nil
+}
+
+// bufferWriter escapes pairs into a buffer in the order they are written. In
+// valuesOnly mode the keys are left out, for a value encoded on its own rather
+// than as part of a form.
+type bufferWriter struct {
+	buf        []byte
+	started    bool
+	valuesOnly bool
+}
+
+func (w *bufferWriter) writePair(key, value string) error {
+	if w.started {
+		w.buf = append(w.buf, '&')
+	}
+	w.started = true
+	if !w.valuesOnly {
+		w.buf = appendQueryEscape(w.buf, key)
+		w.buf = append(w.buf, '=')
+	}
+	w.buf = appendQueryEscape(w.buf, value)
+	return nil
+}
+
+const upperhex = "0123456789ABCDEF"
+
+// appendQueryEscape appends s to dst, escaped as [url.QueryEscape] would
+// escape it, without allocating a string for the result.
+func appendQueryEscape(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			dst = append(dst, c)
+		case c == ' ':
+			dst = append(dst, '+')
+		default:
+			dst = append(dst, '%', upperhex[c>>4], upperhex[c&15])
+		}
+	}
+	return dst
+}
+
+func marshal(e *encodeState, v reflect.Value) ([]byte, error) {
 	if v.Kind() == reflect.Pointer {
 		if v.IsNil() {
-			return []byte{}, nil
+			return e.buf.buf, nil
 		}
 		v = v.Elem()
 	}
-	if isStructValue(v) {
-		return marshalValue(v, marshalStruct)
+
+	var err error
+	switch {
+	case !writesNestedKeys(v.Type()):
+		e.buf.valuesOnly = true
+		err = typeEncoder(v.Type())(e, "", v)
+	case e.opts.sortKeys:
+		err = marshalSorted(e, reflect.Indirect(v))
+	default:
+		err = typeEncoder(v.Type())(e, "", v)
 	}
-	if isMapValue(v) {
-		return marshalValue(v, marshalMap)
+	if err != nil {
+		return nil, fmt.Errorf("form: failed to marshal: %w", err)
 	}
-	return marshalPrimitive(v)
+	return e.buf.buf, nil
 }
 
-func isStructValue(v reflect.Value) bool {
-	return v.Kind() == reflect.Struct ||
-		(v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct)
+// marshalSorted encodes v with its pairs sorted by key, as set with
+// [WithSortKeys]. Sorting needs every pair of v, so they are collected before
+// any is written to e.w.
+func marshalSorted(e *encodeState, v reflect.Value) error {
+	w := e.w
+	data := url.Values{}
+	e.w = valuesWriter(data)
+	err := typeEncoder(v.Type())(e, "", v)
+	e.w = w
+	if err != nil {
+		return err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		for _, value := range data[key] {
+			if err := w.writePair(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func isMapValue(v reflect.Value) bool {
-	return v.Kind() == reflect.Map ||
-		(v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Map)
+// marshalForm writes the form data of a Marshaler to e.w, checked and
+// re-escaped but with its pairs kept in order unless they are to be sorted.
+func marshalForm(e *encodeState, m Marshaler) error {
+	b, err := m.MarshalForm()
+	if err != nil {
+		return fmt.Errorf("form: failed to marshal: %w", err)
+	}
+	var values Values
+	if err := values.UnmarshalForm(b); err != nil {
+		return err
+	}
+	if e.opts.sortKeys {
+		slices.SortStableFunc(values, func(a, b Pair) int {
+			return cmp.Compare(a.Key, b.Key)
+		})
+	}
+	for _, p := range values {
+		if err := e.w.writePair(p.Key, p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func isCompositeValue(v reflect.Value) bool {
-	return isStructValue(v) || isMapValue(v)
+// An encoderFunc writes the form encoding of v under key. Structs, maps and
+// slices of them write nested keys below it.
+type encoderFunc func(e *encodeState, key string, v reflect.Value) error
+
+var encoderCache sync.Map // map[reflect.Type]encoderFunc
+
+// typeEncoder returns the encoder for type t, building and caching it on first
+// use.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := encoderCache.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+
+	// To deal with recursive types, populate the map with an indirect func
+	// before we build it. This type waits on the real func (f) to be ready and
+	// then calls it. This indirect func is only used for recursive types.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, key string, v reflect.Value) error {
+		wg.Wait()
+		return f(e, key, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	// Compute the real encoder and replace the indirect func with it.
+	f = newTypeEncoder(t, true)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
 }
 
-func marshalForm(m Marshaler) ([]byte, error) {
+var (
+	marshalerType     = reflect.TypeFor[Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// newTypeEncoder constructs an encoderFunc for a type. The returned encoder
+// only checks CanAddr when allowAddr is true.
+func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	// Files and readers come first, so that a destination that cannot hold
+	// them rejects them rather than encoding their fields.
+	if t == fileType || t.Implements(readerType) {
+		return fileEncoder
+	}
+	if t.Kind() != reflect.Pointer && allowAddr && reflect.PointerTo(t).Implements(readerType) {
+		return newCondAddrEncoder(fileEncoder, newTypeEncoder(t, false))
+	}
+
+	if t == valuesType {
+		return valuesEncoder
+	}
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+	// A value whose pointer is a Marshaler is written as one even if it
+	// cannot be addressed, as writesNestedKeys expects, by calling the
+	// method on a copy.
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(marshalerType) {
+		return addrMarshalerEncoder
+	}
+
+	if t == timeType {
+		return defaultTimeFormat.encode
+	}
+	if t == durationType {
+		return durationEncoder
+	}
+
+	// Types that only know how to marshal themselves as text, such as
+	// netip.Addr or big.Int, are written as a single value. A Marshaler
+	// takes precedence over them.
+	if t.Implements(textMarshalerType) {
+		return textMarshalerEncoder
+	}
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType) {
+		return addrTextMarshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatEncoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Slice:
+		if isByteSlice(t) {
+			return base64Bytes.encode
+		}
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newSliceEncoder(t)
+	case reflect.Pointer:
+		return newPtrEncoder(t)
+	default:
+		return unsupportedTypeEncoder
+	}
+}
+
+func boolEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, strconv.FormatBool(v.Bool()))
+}
+
+func intEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, strconv.FormatInt(v.Int(), 10))
+}
+
+func uintEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, strconv.FormatUint(v.Uint(), 10))
+}
+
+func floatEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()))
+}
+
+func stringEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, v.String())
+}
+
+func interfaceEncoder(e *encodeState, key string, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	return typeEncoder(v.Elem().Type())(e, key, v.Elem())
+}
+
+func unsupportedTypeEncoder(_ *encodeState, _ string, v reflect.Value) error {
+	return &UnsupportedTypeError{v.Type()}
+}
+
+func marshalerEncoder(e *encodeState, key string, v reflect.Value) error {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	m := v.Interface().(Marshaler)
 	b, err := m.MarshalForm()
 	if err != nil {
-		return nil, fmt.Errorf("form: failed to marshal: %w", err)
+		return &MarshalerError{Field: key, Type: v.Type(), Err: err}
 	}
-	values, err := url.ParseQuery(string(b))
+	return e.w.writePair(key, string(b))
+}
+
+func addrMarshalerEncoder(e *encodeState, key string, v reflect.Value) error {
+	m := addressable(v).Interface().(Marshaler)
+	b, err := m.MarshalForm()
 	if err != nil {
-		return nil, fmt.Errorf("form: invalid form data: %w", err)
+		return &MarshalerError{Field: key, Type: v.Type(), Err: err}
 	}
-	return []byte(values.Encode()), nil
+	return e.w.writePair(key, string(b))
 }
 
-func marshalPrimitive(v reflect.Value) ([]byte, error) {
-	values, err := get(v)
+func textMarshalerEncoder(e *encodeState, key string, v reflect.Value) error {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	m := v.Interface().(encoding.TextMarshaler)
+	b, err := m.MarshalText()
 	if err != nil {
-		return nil, fmt.Errorf("form: failed to marshal: %w", err)
+		return &MarshalerError{Field: key, Type: v.Type(), Err: err, sourceFunc: "MarshalText"}
 	}
-	if len(values) == 1 {
-		return []byte(url.QueryEscape(values[0])), nil
-	} else if len(values) > 1 {
-		result := make([]string, len(values))
-		for i, val := range values {
-			result[i] = url.QueryEscape(val)
-		}
-		return []byte(strings.Join(result, "&")), nil
+	return e.w.writePair(key, string(b))
+}
+
+func addrTextMarshalerEncoder(e *encodeState, key string, v reflect.Value) error {
+	m := addressable(v).Interface().(encoding.TextMarshaler)
+	b, err := m.MarshalText()
+	if err != nil {
+		return &MarshalerError{Field: key, Type: v.Type(), Err: err, sourceFunc: "MarshalText"}
 	}
-	return []byte{}, nil
+	return e.w.writePair(key, string(b))
 }
 
-type marshalerFunc func(v reflect.Value) (url.Values, error)
+// addressable returns a pointer to v, or to a copy of v if it cannot be
+// addressed, so that methods with pointer receivers can be called on it.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
 
-func marshalValue(v reflect.Value, fn marshalerFunc) ([]byte, error) {
-	rv := reflect.Indirect(v)
-	values, err := fn(rv)
-	if err != nil {
-		return nil, fmt.Errorf("form: failed to marshal: %w", err)
+func fileEncoder(e *encodeState, key string, v reflect.Value) error {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
 	}
-	return []byte(values.Encode()), nil
+	f, _ := assertFile(v)
+	return marshalFile(e, key, f)
 }
 
-func marshalStruct(v reflect.Value) (url.Values, error) {
-	tags := tags(v)
-	data := url.Values{}
-	for i := range v.Type().NumField() {
-		tag := tags[i]
-		if tag.Ignore {
+// structEncoder flattens the fields of a struct into nested keys, so that a
+// field is written as address[city]=London rather than as a single escaped
+// sub-form.
+type structEncoder struct {
+	fields    *structFields
+	fieldEncs []encoderFunc
+}
+
+func (se structEncoder) encode(e *encodeState, prefix string, v reflect.Value) error {
+	for i := range se.fields.list {
+		f := &se.fields.list[i]
+		fv := v.Field(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
 			continue
 		}
-		key := tag.Name
-		if key == "" {
-			continue
+		if err := se.fieldEncs[i](e, e.opts.keySyntax.nest(prefix, f.name), fv); err != nil {
+			return err
 		}
-		fv := v.Field(i)
-		if tag.Omit && isEmptyValue(fv) {
-			continue
-		}
-		if val, err := get(fv); err == nil && len(val) > 0 {
-			data[key] = val
+	}
+	return nil
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	se := structEncoder{fields: cachedTypeFields(t)}
+	se.fieldEncs = make([]encoderFunc, len(se.fields.list))
+	for i, f := range se.fields.list {
+		se.fieldEncs[i] = newFieldEncoder(&f)
+	}
+	return se.encode
+}
+
+// newFieldEncoder returns the encoder of the struct field f. The options in
+// its tag apply to every value of the type they describe, whether held
+// directly or through pointers, slices, arrays and maps.
+func newFieldEncoder(f *field) encoderFunc {
+	if !f.hasFormat() {
+		return typeEncoder(f.typ)
+	}
+	return newFormatEncoder(f.typ, f)
+}
+
+func newFormatEncoder(t reflect.Type, f *field) encoderFunc {
+	switch {
+	case f.formatsTime(t):
+		return f.time.encode
+	case f.formatsBytes(t):
+		return f.bytes.encode
+	case !holdsFormatted(t, f):
+		return typeEncoder(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		pe := ptrEncoder{newFormatEncoder(t.Elem(), f)}
+		return pe.encode
+	case reflect.Slice, reflect.Array:
+		se := sliceEncoder{elemEnc: newFormatEncoder(t.Elem(), f)}
+		return se.encode
+	case reflect.Map:
+		if !isMarshalableKey(t.Key()) {
+			return unsupportedTypeEncoder
 		}
+		me := mapEncoder{elemEnc: newFormatEncoder(t.Elem(), f)}
+		return me.encode
 	}
-	return data, nil
+	return typeEncoder(t)
 }
 
-func marshalMap(v reflect.Value) (url.Values, error) {
-	data := url.Values{}
+// holdsFormatted reports whether t is, or holds through pointers, slices,
+// arrays and maps, a value whose format is set by the tag of f.
+func holdsFormatted(t reflect.Type, f *field) bool {
+	if f.formatsTime(t) || f.formatsBytes(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return holdsFormatted(t.Elem(), f)
+	}
+	return false
+}
+
+// mapEncoder writes the entries of a map as nested keys, in key order or the
+// order set with [WithMapKeyOrder].
+type mapEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (me mapEncoder) encode(e *encodeState, prefix string, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	if err := e.enterPointer(v); err != nil {
+		return err
+	}
+	defer e.leavePointer(v)
+
+	keys := make([]mapKey, v.Len())
+	for i, iter := 0, v.MapRange(); iter.Next(); i++ {
+		name, err := resolveKeyName(prefix, iter.Key())
+		if err != nil {
+			return err
+		}
+		keys[i] = mapKey{v: iter.Key(), name: name}
+	}
 
-	// Validate map key type - only string keys are supported
-	if v.Type().Key().Kind() != reflect.String {
-		return nil, fmt.Errorf("form: unsupported map key type: %v", v.Type().Key())
+	compare := e.opts.mapKeyOrder
+	if compare == nil {
+		compare = cmp.Compare[string]
 	}
+	slices.SortFunc(keys, func(a, b mapKey) int {
+		return compare(a.name, b.name)
+	})
 
-	for _, key := range v.MapKeys() {
-		keyStr := key.String()
-		mapVal := v.MapIndex(key)
+	for _, key := range keys {
+		mapVal := v.MapIndex(key.v)
 		if isEmptyValue(mapVal) {
 			continue
 		}
-		if val, err := get(mapVal); err == nil && len(val) > 0 {
-			data[keyStr] = val
+		if err := me.elemEnc(e, e.opts.keySyntax.nest(prefix, key.name), mapVal); err != nil {
+			return err
 		}
 	}
-	return data, nil
+	return nil
 }
 
-func assertMarshaler(v reflect.Value) (Marshaler, bool) {
-	if v.CanAddr() {
-		if m, ok := v.Addr().Interface().(Marshaler); ok {
-			return m, true
-		}
+func newMapEncoder(t reflect.Type) encoderFunc {
+	if !isMarshalableKey(t.Key()) {
+		return unsupportedTypeEncoder
 	}
-	if m, ok := v.Interface().(Marshaler); ok {
-		return m, true
+	me := mapEncoder{elemEnc: typeEncoder(t.Elem())}
+	return me.encode
+}
+
+// A mapKey is a map key along with the name it is written under.
+type mapKey struct {
+	v    reflect.Value
+	name string
+}
+
+// isMarshalableKey reports whether maps with keys of type t can be encoded:
+// strings, types that marshal themselves as text, and the scalars that
+// [isScalarKey] accepts.
+func isMarshalableKey(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Implements(textMarshalerType) || isScalarKey(t)
+}
+
+// isScalarKey reports whether t is a boolean or numeric type, which map keys
+// may be as well as strings.
+func isScalarKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
 	}
-	return nil, false
+	return false
 }
 
-func get(v reflect.Value) ([]string, error) {
-	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
-		if v.IsNil() {
-			return nil, nil
-		}
-		v = v.Elem()
+// resolveKeyName returns the name a key of the map at prefix is written under.
+// As with encoding/json, a string is used as it is, even if it could marshal
+// itself as text, and a scalar is formatted just as a value of its type would
+// be. A nil pointer or interface is written under the empty name.
+func resolveKeyName(prefix string, k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
 	}
-	if v.Kind() == reflect.Slice {
-		return getSlice(v)
+	if (k.Kind() == reflect.Pointer || k.Kind() == reflect.Interface) && k.IsNil() {
+		return "", nil
 	}
-	if m, ok := assertMarshaler(v); ok {
-		b, err := m.MarshalForm()
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
 		if err != nil {
-			return nil, err
+			return "", &MarshalerError{Field: prefix, Type: k.Type(), Err: err, sourceFunc: "MarshalText"}
 		}
-		return []string{string(b)}, nil
+		return string(b), nil
 	}
-	if isCompositeValue(v) {
-		values, err := marshal(v)
-		if err != nil {
-			return nil, err
-		}
-		return []string{string(values)}, nil
+	switch k.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'f', -1, k.Type().Bits()), nil
 	}
-	return []string{getScalar(v)}, nil
+	return "", &UnsupportedTypeError{k.Type()}
 }
 
-func getSlice(v reflect.Value) ([]string, error) {
-	values := make([]string, v.Len())
+// sliceEncoder writes every element of a slice or array under the key of the
+// slice.
+// Structs and maps are indexed, as in children[0][name], so that the fields of
+// one element stay together. Scalars are keyed according to the slice style
+// in effect.
+type sliceEncoder struct {
+	elemEnc encoderFunc
+	nested  bool
+}
+
+func (se sliceEncoder) encode(e *encodeState, key string, v reflect.Value) error {
+	if v.Kind() == reflect.Slice {
+		if err := e.enterPointer(v); err != nil {
+			return err
+		}
+		defer e.leavePointer(v)
+	}
 	for i := range v.Len() {
 		elem := v.Index(i)
-		if m, ok := assertMarshaler(elem); ok {
-			b, err := m.MarshalForm()
-			if err != nil {
-				return nil, err
-			}
-			values[i] = string(b)
-			continue
+		elemKey := key
+		if se.nested || isNestedInterface(elem) || e.opts.sliceStyle == IndexedKeys {
+			elemKey = e.opts.keySyntax.nest(key, strconv.Itoa(i))
+		} else if e.opts.sliceStyle == EmptyBrackets {
+			elemKey = e.opts.keySyntax.nest(key, "")
+		}
+		if err := se.elemEnc(e, elemKey, elem); err != nil {
+			return err
 		}
-		values[i] = getScalar(elem)
 	}
-	return values, nil
+	return nil
 }
 
-func getScalar(v reflect.Value) string {
-	switch v.Kind() {
-	case reflect.String:
-		return v.String()
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(v.Int(), 10)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return strconv.FormatUint(v.Uint(), 10)
-	case reflect.Float32, reflect.Float64:
-		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
-	case reflect.Bool:
-		return strconv.FormatBool(v.Bool())
-	default:
-		panic("form: unsupported type: " + v.Type().String())
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	se := sliceEncoder{
+		elemEnc: typeEncoder(t.Elem()),
+		nested:  writesNestedKeys(t.Elem()),
+	}
+	return se.encode
+}
+
+// ptrEncoder writes the value a pointer points to, and nothing for nil.
+type ptrEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (pe ptrEncoder) encode(e *encodeState, key string, v reflect.Value) error {
+	if v.IsNil() {
+		return nil
+	}
+	if err := e.enterPointer(v); err != nil {
+		return err
 	}
+	defer e.leavePointer(v)
+	return pe.elemEnc(e, key, v.Elem())
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	enc := ptrEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+// condAddrEncoder uses canAddrEnc if v.CanAddr(), and elseEnc otherwise.
+type condAddrEncoder struct {
+	canAddrEnc, elseEnc encoderFunc
+}
+
+func (ce condAddrEncoder) encode(e *encodeState, key string, v reflect.Value) error {
+	if v.CanAddr() {
+		return ce.canAddrEnc(e, key, v)
+	}
+	return ce.elseEnc(e, key, v)
+}
+
+// newCondAddrEncoder returns an encoder that checks whether its value CanAddr
+// and delegates to canAddrEnc if so, else to elseEnc.
+func newCondAddrEncoder(canAddrEnc, elseEnc encoderFunc) encoderFunc {
+	enc := condAddrEncoder{canAddrEnc: canAddrEnc, elseEnc: elseEnc}
+	return enc.encode
+}
+
+// writesNestedKeys reports whether values of type t are written as nested keys
+// rather than as a single value.
+func writesNestedKeys(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == fileType || reflect.PointerTo(t).Implements(marshalerType) ||
+		reflect.PointerTo(t).Implements(textMarshalerType) {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+// isNestedInterface reports whether v is an interface holding a value that is
+// written as nested keys.
+func isNestedInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && !v.IsNil() && writesNestedKeys(v.Elem().Type())
 }
 
 func isEmptyValue(v reflect.Value) bool {
@@ -225,6 +900,8 @@ func isEmptyValue(v reflect.Value) bool {
 		reflect.Float32, reflect.Float64,
 		reflect.Interface, reflect.Pointer:
 		return v.IsZero()
+	case reflect.Struct:
+		return v.Type() == timeType && v.Interface().(time.Time).IsZero()
 	}
 	return false
 }
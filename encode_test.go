@@ -1,7 +1,13 @@
 package encoding_test
 
 import (
+	stdencoding "encoding"
+	"errors"
+	"io"
+	"net/netip"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,11 +18,10 @@ func TestMarshal_Primitives(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name      string
-		input     any
-		want      []byte
-		wantErr   bool
-		wantPanic bool
+		name    string
+		input   any
+		want    []byte
+		wantErr bool
 	}{
 		{
 			name:  "bool true",
@@ -104,27 +109,20 @@ func TestMarshal_Primitives(t *testing.T) {
 			want:  []byte("3.14159"),
 		},
 		{
-			name:      "complex64",
-			input:     complex64(1),
-			wantPanic: true,
+			name:    "complex64",
+			input:   complex64(1),
+			wantErr: true,
 		},
 		{
-			name:      "complex128",
-			input:     complex128(1),
-			wantPanic: true,
+			name:    "complex128",
+			input:   complex128(1),
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			defer func() {
-				r := recover()
-				if (r != nil) != tt.wantPanic {
-					t.Errorf("Marshal() panic = %v, wantPanic %v", r, tt.wantPanic)
-				}
-			}()
-
 			got, err := encoding.Marshal(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
@@ -146,21 +144,20 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 	optionalVal := "optional_value"
 
 	tests := []struct {
-		name      string
-		input     any
-		want      []byte
-		wantErr   bool
-		wantPanic bool
+		name    string
+		input   any
+		want    []byte
+		wantErr bool
 	}{
 		{
-			name:      "chan int",
-			input:     make(chan int),
-			wantPanic: true,
+			name:    "chan int",
+			input:   make(chan int),
+			wantErr: true,
 		},
 		{
-			name:      "func",
-			input:     func() {},
-			wantPanic: true,
+			name:    "func",
+			input:   func() {},
+			wantErr: true,
 		},
 		{
 			name:  "pointer to int",
@@ -177,6 +174,13 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 			input: nil,
 			want:  []byte(""),
 		},
+		{
+			name: "nil marshaler field",
+			input: struct {
+				Date encoding.Marshaler `form:"date"`
+			}{},
+			want: []byte(""),
+		},
 		{
 			name:  "slice of ints",
 			input: []int{1, 2, 3},
@@ -197,6 +201,30 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 			input: []int{},
 			want:  []byte(""),
 		},
+		{
+			name:  "array of ints",
+			input: [3]int{1, 2, 3},
+			want:  []byte("1&2&3"),
+		},
+		{
+			name: "struct with arrays",
+			input: ArrayForm{
+				Scores: [3]int{1, 2, 3},
+				Pair:   [2]Item{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}},
+				Raw:    [2]byte{7, 8},
+			},
+			want: pairsToBytes(
+				"scores", "1",
+				"scores", "2",
+				"scores", "3",
+				"pair[0][sku]", "a",
+				"pair[0][qty]", "1",
+				"pair[1][sku]", "b",
+				"pair[1][qty]", "2",
+				"raw", "7",
+				"raw", "8",
+			),
+		},
 		{
 			name:  "nil slice",
 			input: []int(nil),
@@ -218,8 +246,23 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 			want:  valuesToBytes(url.Values{"a": {"1"}, "b": {"string"}, "c": {"true"}, "d": {"3.14"}}),
 		},
 		{
-			name:    "map with non-string key",
-			input:   map[int]int{1: 1, 2: 2, 3: 3},
+			name:  "map with int keys",
+			input: map[int]int{1: 1, 2: 2, 3: 3},
+			want:  []byte("1=1&2=2&3=3"),
+		},
+		{
+			name:  "map with typed string keys",
+			input: map[Code]int{"a": 1, "b": 2},
+			want:  []byte("a=1&b=2"),
+		},
+		{
+			name:  "map with bool and float keys",
+			input: map[bool]map[float64]string{true: {1.5: "x"}},
+			want:  []byte("true%5B1.5%5D=x"),
+		},
+		{
+			name:    "map with struct keys",
+			input:   map[Address]int{{City: "London"}: 1},
 			wantErr: true,
 		},
 		{
@@ -235,12 +278,12 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 		{
 			name:  "map with nested struct",
 			input: map[string]any{"user": BasicForm{Name: "john", Age: 20, Aliases: []string{"j"}}},
-			want:  valuesToBytes(url.Values{"user": {"age=20&aliases=j&name=john"}}),
+			want:  pairsToBytes("user[name]", "john", "user[aliases]", "j", "user[age]", "20"),
 		},
 		{
 			name:  "map with nested map",
 			input: map[string]any{"data": map[string]int{"x": 1, "y": 2}},
-			want:  valuesToBytes(url.Values{"data": {"x=1&y=2"}}),
+			want:  valuesToBytes(url.Values{"data[x]": {"1"}, "data[y]": {"2"}}),
 		},
 		{
 			name: "basic form",
@@ -249,11 +292,12 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 				Aliases: []string{"johnny", "jonny"},
 				Age:     20,
 			},
-			want: valuesToBytes(url.Values{
-				"age":     {"20"},
-				"aliases": {"johnny", "jonny"},
-				"name":    {"john"},
-			}),
+			want: pairsToBytes(
+				"name", "john",
+				"aliases", "johnny",
+				"aliases", "jonny",
+				"age", "20",
+			),
 		},
 		{
 			name: "complex form with custom type",
@@ -266,14 +310,15 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 				Private:   "hidden",
 				Optional:  &optionalVal,
 			},
-			want: valuesToBytes(url.Values{
-				"age":        {"25"},
-				"aliases":    {"janet", "jan"},
-				"created_at": {"2025.02.08"},
-				"id":         {"1"},
-				"name":       {"jane"},
-				"optional":   {"optional_value"},
-			}),
+			want: pairsToBytes(
+				"id", "1",
+				"name", "jane",
+				"aliases", "janet",
+				"aliases", "jan",
+				"age", "25",
+				"created_at", "2025.02.08",
+				"optional", "optional_value",
+			),
 		},
 		{
 			name: "form with ignored fields",
@@ -286,33 +331,641 @@ func TestMarshal_CompositeTypes(t *testing.T) {
 				Omitted: "",
 				Complex: MyDate(baseTime),
 			},
+			want: pairsToBytes(
+				"public", "visible",
+				"NoTag", "value",
+				"Empty", "value",
+				"complex", "2025.02.08",
+			),
+		},
+		{
+			name: "nested struct",
+			input: NestedForm{
+				Name:    "john",
+				Address: Address{City: "London", Zip: "N1 9GU"},
+				Billing: &Address{City: "Leeds"},
+				Metadata: map[string]string{
+					"source": "web",
+				},
+			},
+			want: pairsToBytes(
+				"name", "john",
+				"address[city]", "London",
+				"address[zip]", "N1 9GU",
+				"billing[city]", "Leeds",
+				"billing[zip]", "",
+				"metadata[source]", "web",
+			),
+		},
+		{
+			name: "nested struct with omitted fields",
+			input: NestedForm{
+				Name: "john",
+			},
+			want: pairsToBytes(
+				"name", "john",
+				"address[city]", "",
+				"address[zip]", "",
+			),
+		},
+		{
+			name: "slice of structs",
+			input: OrderForm{
+				Tags: []string{"a", "b"},
+				Items: []Item{
+					{SKU: "x1", Qty: 1},
+					{SKU: "x2", Qty: 2},
+				},
+			},
+			want: pairsToBytes(
+				"tags", "a",
+				"tags", "b",
+				"items[0][sku]", "x1",
+				"items[0][qty]", "1",
+				"items[1][sku]", "x2",
+				"items[1][qty]", "2",
+			),
+		},
+		{
+			name: "slice of struct pointers with nil element",
+			input: map[string][]*Item{
+				"items": {{SKU: "x1"}, nil, {SKU: "x3"}},
+			},
+			want: pairsToBytes(
+				"items[0][sku]", "x1",
+				"items[0][qty]", "0",
+				"items[2][sku]", "x3",
+				"items[2][qty]", "0",
+			),
+		},
+		{
+			name: "recursive struct",
+			input: Category{
+				Name: "root",
+				Children: []Category{
+					{Name: "a", Children: []Category{{Name: "a1"}}},
+					{Name: "b"},
+				},
+			},
+			want: pairsToBytes(
+				"name", "root",
+				"children[0][name]", "a",
+				"children[0][children][0][name]", "a1",
+				"children[1][name]", "b",
+			),
+		},
+		{
+			name:  "nil reader",
+			input: ReportUpload{Name: "q1"},
+			want:  pairsToBytes("name", "q1", "address[city]", "", "address[zip]", ""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, got); diff != "" {
+					t.Errorf("Marshal() mismatch %s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshal_Options(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   any
+		opts    []encoding.Option
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "repeated keys",
+			input: OrderForm{Tags: []string{"a", "b"}},
+			opts:  []encoding.Option{encoding.WithSliceStyle(encoding.RepeatedKeys)},
+			want:  []byte("tags=a&tags=b"),
+		},
+		{
+			name:  "empty brackets",
+			input: OrderForm{Tags: []string{"a", "b"}},
+			opts:  []encoding.Option{encoding.WithSliceStyle(encoding.EmptyBrackets)},
+			want:  []byte("tags%5B%5D=a&tags%5B%5D=b"),
+		},
+		{
+			name:  "indexed keys",
+			input: OrderForm{Tags: []string{"a", "b"}},
+			opts:  []encoding.Option{encoding.WithSliceStyle(encoding.IndexedKeys)},
+			want:  []byte("tags%5B0%5D=a&tags%5B1%5D=b"),
+		},
+		{
+			name:  "empty brackets do not apply to structs",
+			input: OrderForm{Items: []Item{{SKU: "x1", Qty: 1}}},
+			opts:  []encoding.Option{encoding.WithSliceStyle(encoding.EmptyBrackets)},
+			want:  []byte("items%5B0%5D%5Bsku%5D=x1&items%5B0%5D%5Bqty%5D=1"),
+		},
+		{
+			name: "dot syntax",
+			input: NestedForm{
+				Name:    "john",
+				Address: Address{City: "London", Zip: "N1 9GU"},
+			},
+			opts: []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			want: []byte("name=john&address.city=London&address.zip=N1+9GU"),
+		},
+		{
+			name: "dot syntax with slice of structs",
+			input: OrderForm{
+				Tags:  []string{"a", "b"},
+				Items: []Item{{SKU: "x1", Qty: 1}},
+			},
+			opts: []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			want: []byte("tags=a&tags=b&items.0.sku=x1&items.0.qty=1"),
+		},
+		{
+			name: "sort keys",
+			input: NestedForm{
+				Name:    "john",
+				Address: Address{City: "London", Zip: "N1 9GU"},
+			},
+			opts: []encoding.Option{encoding.WithSortKeys()},
 			want: valuesToBytes(url.Values{
-				"Empty":   {"value"},
-				"NoTag":   {"value"},
-				"complex": {"2025.02.08"},
-				"public":  {"visible"},
+				"address[city]": {"London"},
+				"address[zip]":  {"N1 9GU"},
+				"name":          {"john"},
 			}),
 		},
+		{
+			name:  "sort keys keeps order of values",
+			input: BasicForm{Name: "john", Aliases: []string{"z", "a"}},
+			opts:  []encoding.Option{encoding.WithSortKeys()},
+			want:  []byte("age=0&aliases=z&aliases=a&name=john"),
+		},
+		{
+			name:  "map key order",
+			input: map[string]int{"a": 1, "b": 2, "c": 3},
+			opts: []encoding.Option{encoding.WithMapKeyOrder(func(a, b string) int {
+				return strings.Compare(b, a)
+			})},
+			want: []byte("c=3&b=2&a=1"),
+		},
+		{
+			name: "map key order in nested map",
+			input: NestedForm{
+				Name:     "john",
+				Metadata: map[string]string{"a": "1", "b": "2"},
+			},
+			opts: []encoding.Option{encoding.WithMapKeyOrder(func(a, b string) int {
+				return strings.Compare(b, a)
+			})},
+			want: pairsToBytes(
+				"name", "john",
+				"address[city]", "",
+				"address[zip]", "",
+				"metadata[b]", "2",
+				"metadata[a]", "1",
+			),
+		},
+		{
+			name:  "dot syntax with indexed keys",
+			input: OrderForm{Tags: []string{"a", "b"}},
+			opts: []encoding.Option{
+				encoding.WithKeySyntax(encoding.DotSyntax),
+				encoding.WithSliceStyle(encoding.IndexedKeys),
+			},
+			want: []byte("tags.0=a&tags.1=b"),
+		},
+		{
+			name:  "dot syntax with empty brackets",
+			input: OrderForm{Tags: []string{"a", "b"}},
+			opts: []encoding.Option{
+				encoding.WithKeySyntax(encoding.DotSyntax),
+				encoding.WithSliceStyle(encoding.EmptyBrackets),
+			},
+			want: []byte("tags=a&tags=b"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			defer func() {
-				r := recover()
-				if (r != nil) != tt.wantPanic {
-					t.Errorf("Marshal() panic = %v, wantPanic %v", r, tt.wantPanic)
+			got, err := encoding.Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, got); diff != "" {
+					t.Errorf("Marshal() mismatch %s", diff)
 				}
-			}()
+			}
+		})
+	}
+}
+
+func TestMarshal_TextMarshaler(t *testing.T) {
+	t.Parallel()
+
+	addr := netip.MustParseAddr("10.0.0.1")
+	gateway := netip.MustParseAddr("10.0.0.254")
+
+	tests := []struct {
+		name    string
+		input   any
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "struct fields",
+			input: NetworkForm{
+				Addr:    addr,
+				Gateway: &gateway,
+				Level:   LevelHigh,
+			},
+			want: pairsToBytes(
+				"addr", "10.0.0.1",
+				"gateway", "10.0.0.254",
+				"level", "high",
+			),
+		},
+		{
+			name:  "slice elements",
+			input: NetworkForm{Addr: addr, Peers: []netip.Addr{addr, gateway}},
+			want: pairsToBytes(
+				"addr", "10.0.0.1",
+				"peers", "10.0.0.1",
+				"peers", "10.0.0.254",
+				"level", "low",
+			),
+		},
+		{
+			name:  "map values",
+			input: map[string]Level{"a": LevelLow, "b": LevelHigh},
+			want:  []byte("b=high"),
+		},
+		{
+			name:  "map keys",
+			input: NetworkForm{Addr: addr, Limits: map[Level]int{LevelHigh: 10, LevelLow: 5}},
+			want: pairsToBytes(
+				"addr", "10.0.0.1",
+				"level", "low",
+				"limits[high]", "10",
+				"limits[low]", "5",
+			),
+		},
+		{
+			name: "nil interface field",
+			input: struct {
+				Addr stdencoding.TextMarshaler `form:"addr"`
+			}{},
+			want: []byte(""),
+		},
+		{
+			name:  "nil interface map key",
+			input: map[stdencoding.TextMarshaler]int{nil: 1, LevelHigh: 2},
+			want:  pairsToBytes("", "1", "high", "2"),
+		},
+		{
+			name:  "marshaler takes precedence",
+			input: NetworkForm{Addr: addr, Version: "v1"},
+			want: pairsToBytes(
+				"addr", "10.0.0.1",
+				"level", "low",
+				"version", "form-v1",
+			),
+		},
+		{
+			name:  "top-level value",
+			input: addr,
+			want:  []byte("10.0.0.1"),
+		},
+		{
+			name:    "marshal error",
+			input:   NetworkForm{Level: 5},
+			wantErr: true,
+		},
+		{
+			name:    "map key error",
+			input:   map[Level]int{5: 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
 			got, err := encoding.Marshal(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				var merr *encoding.MarshalerError
+				if !errors.As(err, &merr) || !errors.Is(err, errInvalidLevel) {
+					t.Errorf("Marshal() error = %v, want *MarshalerError wrapping %v", err, errInvalidLevel)
+				}
+				return
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshal_PointerMethods(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  any
+		want   []byte
+		target any
+	}{
+		{
+			name:   "top-level marshaler",
+			input:  Filter{Field: "name", Op: "eq"},
+			want:   []byte("field=name&op=eq"),
+			target: &Filter{},
+		},
+		{
+			name:   "top-level text marshaler",
+			input:  Span{From: 1, To: 5},
+			want:   []byte("1-5"),
+			target: &Span{},
+		},
+		{
+			name:  "fields",
+			input: SearchForm{Span: Span{From: 1, To: 5}, Filter: Filter{Field: "name", Op: "eq"}},
+			want: pairsToBytes(
+				"span", "1-5",
+				"filter", "field=name&op=eq",
+			),
+			target: &SearchForm{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+
+			// The value must read back through the same methods.
+			if err := encoding.Unmarshal(got, tt.target); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if diff := diff(tt.input, reflect.ValueOf(tt.target).Elem().Interface()); diff != "" {
+				t.Errorf("Unmarshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshal_MarshalerError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     any
+		opts      []encoding.Option
+		wantField string
+	}{
+		{
+			name:      "nested struct field",
+			input:     map[string]Account{"user": {Name: "john", Address: Contact{Zip: "bad"}}},
+			wantField: "user[address][zip]",
+		},
+		{
+			name:      "nested struct field with dot syntax",
+			input:     map[string]Account{"user": {Name: "john", Address: Contact{Zip: "bad"}}},
+			opts:      []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			wantField: "user.address.zip",
+		},
+		{
+			name:      "slice element",
+			input:     Contact{Zip: "ok", Codes: []Code{"ok", "bad"}},
+			opts:      []encoding.Option{encoding.WithSliceStyle(encoding.IndexedKeys)},
+			wantField: "codes[1]",
+		},
+		{
+			name:      "map element",
+			input:     map[string]Code{"a": "ok", "b": "bad"},
+			wantField: "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := encoding.Marshal(tt.input, tt.opts...)
+			var merr *encoding.MarshalerError
+			if !errors.As(err, &merr) {
+				t.Fatalf("Marshal() error = %v, want *MarshalerError", err)
+			}
+			if merr.Field != tt.wantField {
+				t.Errorf("MarshalerError.Field = %q, want %q", merr.Field, tt.wantField)
+			}
+			if !errors.Is(err, errInvalidCode) {
+				t.Errorf("Marshal() error = %v, want wrapping %v", err, errInvalidCode)
+			}
+		})
+	}
+}
+
+func TestMarshal_UnsupportedErrors(t *testing.T) {
+	t.Parallel()
+
+	type Node struct {
+		Name string `form:"name"`
+		Next *Node  `form:"next"`
+	}
+	cycle := &Node{Name: "a"}
+	cycle.Next = cycle
+
+	t.Run("unsupported type", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := encoding.Marshal(map[string]any{"callback": func() {}})
+		var typeErr *encoding.UnsupportedTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("Marshal() error = %v, want *UnsupportedTypeError", err)
+		}
+		if got, want := typeErr.Type.String(), "func()"; got != want {
+			t.Errorf("UnsupportedTypeError.Type = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("unsupported value", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := encoding.Marshal(cycle)
+		var valueErr *encoding.UnsupportedValueError
+		if !errors.As(err, &valueErr) {
+			t.Fatalf("Marshal() error = %v, want *UnsupportedValueError", err)
+		}
+	})
+
+	t.Run("self-referencing slice", func(t *testing.T) {
+		t.Parallel()
+
+		a := []any{nil}
+		a[0] = a
+		_, err := encoding.Marshal(map[string]any{"a": a})
+		var valueErr *encoding.UnsupportedValueError
+		if !errors.As(err, &valueErr) {
+			t.Fatalf("Marshal() error = %v, want *UnsupportedValueError", err)
+		}
+	})
+}
+
+func TestAppendMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		dst     []byte
+		input   any
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "nil buffer",
+			input: BasicForm{Name: "john", Age: 30},
+			want:  []byte("name=john&age=30"),
+		},
+		{
+			name:  "non-empty buffer",
+			dst:   []byte("/users?"),
+			input: BasicForm{Name: "john", Age: 30},
+			want:  []byte("/users?name=john&age=30"),
+		},
+		{
+			name:  "primitive",
+			dst:   []byte("ids="),
+			input: 42,
+			want:  []byte("ids=42"),
+		},
+		{
+			name:  "nil value",
+			dst:   []byte("a=1"),
+			input: nil,
+			want:  []byte("a=1"),
+		},
+		{
+			name:    "unsupported type",
+			dst:     []byte("a=1"),
+			input:   map[string]any{"callback": func() {}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.AppendMarshal(tt.dst, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AppendMarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
 			if !tt.wantErr {
 				if diff := diff(tt.want, got); diff != "" {
-					t.Errorf("Marshal() mismatch %s", diff)
+					t.Errorf("AppendMarshal() mismatch %s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   any
+		opts    []encoding.Option
+		want    url.Values
+		wantErr bool
+	}{
+		{
+			name:  "struct",
+			input: BasicForm{Name: "john", Aliases: []string{"johnny", "jon"}, Age: 30},
+			want:  url.Values{"name": {"john"}, "aliases": {"johnny", "jon"}, "age": {"30"}},
+		},
+		{
+			name: "nested struct",
+			input: &NestedForm{
+				Name:     "john",
+				Address:  Address{City: "London"},
+				Metadata: map[string]string{"a": "1"},
+			},
+			want: url.Values{
+				"name":          {"john"},
+				"address[city]": {"London"},
+				"address[zip]":  {""},
+				"metadata[a]":   {"1"},
+			},
+		},
+		{
+			name:  "map",
+			input: map[string]int{"a": 1, "b": 2},
+			want:  url.Values{"a": {"1"}, "b": {"2"}},
+		},
+		{
+			name:  "marshaler",
+			input: encoding.Values{{"b", "2"}, {"a", "1"}, {"b", "3"}},
+			want:  url.Values{"a": {"1"}, "b": {"2", "3"}},
+		},
+		{
+			name:  "dot syntax with indexed keys",
+			input: OrderForm{Items: []Item{{SKU: "a", Qty: 1}}},
+			opts: []encoding.Option{
+				encoding.WithKeySyntax(encoding.DotSyntax),
+				encoding.WithSliceStyle(encoding.IndexedKeys),
+			},
+			want: url.Values{"items.0.sku": {"a"}, "items.0.qty": {"1"}},
+		},
+		{
+			name:  "nil pointer",
+			input: (*BasicForm)(nil),
+			want:  url.Values{},
+		},
+		{
+			name:    "primitive",
+			input:   42,
+			wantErr: true,
+		},
+		{
+			name:    "marshaler error",
+			input:   Contact{Zip: "bad"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.MarshalValues(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MarshalValues() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, got); diff != "" {
+					t.Errorf("MarshalValues() mismatch %s", diff)
 				}
 			}
 		})
@@ -403,6 +1056,22 @@ func BenchmarkMarshal(b *testing.B) {
 				Optional:  &optionalVal,
 			},
 		},
+		{
+			name: "nested form",
+			input: NestedForm{
+				Name:     "jane",
+				Address:  Address{City: "London", Zip: "E1 6AN"},
+				Billing:  &Address{City: "Leeds", Zip: "LS1 4AP"},
+				Metadata: map[string]string{"source": "web", "ref": "abc"},
+			},
+		},
+		{
+			name: "slice of structs",
+			input: OrderForm{
+				Tags:  []string{"gift", "express"},
+				Items: []Item{{SKU: "A1", Qty: 2}, {SKU: "B2", Qty: 1}, {SKU: "C3", Qty: 5}},
+			},
+		},
 	}
 	for _, bm := range benchmarks {
 		b.Run(bm.name, func(b *testing.B) {
@@ -414,3 +1083,55 @@ func BenchmarkMarshal(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkAppendMarshal(b *testing.B) {
+	optionalVal := "optional_value"
+
+	benchmarks := []struct {
+		name  string
+		input any
+	}{
+		{
+			name: "basic form",
+			input: &BasicForm{
+				Name:    "john",
+				Aliases: []string{"johnny", "jonny"},
+				Age:     20,
+			},
+		},
+		{
+			name: "complex form",
+			input: &ComplexForm{
+				ID:        1,
+				Name:      "jane",
+				Aliases:   []string{"janet", "jan"},
+				Age:       25,
+				CreatedAt: MyDate(time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC)),
+				Private:   "hidden",
+				Optional:  &optionalVal,
+			},
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name+"/Marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				encoding.Marshal(bm.input)
+			}
+		})
+		b.Run(bm.name+"/AppendMarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			var buf []byte
+			for range b.N {
+				buf, _ = encoding.AppendMarshal(buf[:0], bm.input)
+			}
+		})
+		b.Run(bm.name+"/Encoder", func(b *testing.B) {
+			b.ReportAllocs()
+			encoder := encoding.NewEncoder(io.Discard)
+			for range b.N {
+				encoder.Encode(bm.input)
+			}
+		})
+	}
+}
@@ -71,6 +71,7 @@ func compositeTypes() {
 
 	// URL Values
 	marshalUnmarshal(url.Values{"a": []string{"1", "2"}, "b": []string{"3"}})
+	marshalUnmarshal(encoding.Values{{Key: "b", Value: "1"}, {Key: "a", Value: "2"}, {Key: "b", Value: "3"}})
 
 	// Structs
 	marshalUnmarshal(Panda{Name: "Panda", Species: "Ailuropoda melanoleuca", Age: 5})
@@ -82,12 +83,6 @@ func compositeTypes() {
 }
 
 func marshalUnmarshal[T any](v T) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Recovered in marshalUnmarshal: %v\n", r)
-		}
-	}()
-
 	val, typeName := getValueAndType(v)
 	fmt.Printf("\nMarshaling value: (%s) %+v\n", typeName, val)
 
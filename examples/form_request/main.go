@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -19,6 +20,7 @@ type FormRequest struct {
 func main() {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("POST", "/", strings.NewReader("name=john&age=20&aliases=jonny&aliases=johnny"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// Handle the request
 	handleRequest(w, r)
@@ -30,9 +32,13 @@ func main() {
 func handleRequest(w http.ResponseWriter, r *http.Request) {
 	var req FormRequest
 
-	dec := encoding.NewDecoder(r.Body)
-	if err := dec.Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := encoding.Bind(r, &req); err != nil {
+		var bindErr *encoding.BindError
+		if errors.As(err, &bindErr) {
+			http.Error(w, err.Error(), bindErr.StatusCode)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
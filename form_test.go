@@ -1,7 +1,9 @@
 package encoding_test
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"net/url"
 	"time"
 
@@ -52,8 +54,154 @@ type IgnoredFieldsForm struct {
 	Complex MyDate `form:"complex,omitempty"`
 }
 
+type Address struct {
+	City string `form:"city"`
+	Zip  string `form:"zip"`
+}
+
+type NestedForm struct {
+	Name     string            `form:"name"`
+	Address  Address           `form:"address"`
+	Billing  *Address          `form:"billing,omitempty"`
+	Metadata map[string]string `form:"metadata,omitempty"`
+}
+
+type Item struct {
+	SKU string `form:"sku"`
+	Qty int    `form:"qty"`
+}
+
+type OrderForm struct {
+	Tags  []string `form:"tags,omitempty"`
+	Items []Item   `form:"items,omitempty"`
+}
+
+type ArrayForm struct {
+	Scores [3]int  `form:"scores"`
+	Pair   [2]Item `form:"pair"`
+	Raw    [2]byte `form:"raw"`
+}
+
+type Category struct {
+	Name     string     `form:"name"`
+	Children []Category `form:"children,omitempty"`
+}
+
+var errInvalidCode = errors.New("invalid code")
+
+// Code is a Marshaler that fails for any value other than "ok".
+type Code string
+
+func (c Code) MarshalForm() ([]byte, error) {
+	if c != "ok" {
+		return nil, errInvalidCode
+	}
+	return []byte(c), nil
+}
+
+type Account struct {
+	Name    string  `form:"name"`
+	Address Contact `form:"address"`
+}
+
+type Contact struct {
+	Zip   Code   `form:"zip"`
+	Codes []Code `form:"codes,omitempty"`
+}
+
+var errInvalidLevel = errors.New("invalid level")
+
+// Level is an enum that marshals itself as text.
+type Level int
+
+const (
+	LevelLow Level = iota
+	LevelHigh
+)
+
+func (l Level) MarshalText() ([]byte, error) {
+	switch l {
+	case LevelLow:
+		return []byte("low"), nil
+	case LevelHigh:
+		return []byte("high"), nil
+	}
+	return nil, errInvalidLevel
+}
+
+func (l *Level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = LevelLow
+	case "high":
+		*l = LevelHigh
+	default:
+		return errInvalidLevel
+	}
+	return nil
+}
+
+// Version implements both Marshaler and TextMarshaler, and records which of
+// them was used.
+type Version string
+
+func (v Version) MarshalForm() ([]byte, error) { return []byte("form-" + v), nil }
+func (v Version) MarshalText() ([]byte, error) { return []byte("text-" + v), nil }
+
+func (v *Version) UnmarshalForm(b []byte) error {
+	*v = Version("form-" + string(b))
+	return nil
+}
+
+func (v *Version) UnmarshalText(b []byte) error {
+	*v = Version("text-" + string(b))
+	return nil
+}
+
+// Span marshals itself as text only through a pointer.
+type Span struct{ From, To int }
+
+func (s *Span) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "%d-%d", s.From, s.To), nil
+}
+
+func (s *Span) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d-%d", &s.From, &s.To)
+	return err
+}
+
+// Filter marshals itself as form data only through a pointer.
+type Filter struct{ Field, Op string }
+
+func (f *Filter) MarshalForm() ([]byte, error) {
+	return []byte(url.Values{"field": {f.Field}, "op": {f.Op}}.Encode()), nil
+}
+
+func (f *Filter) UnmarshalForm(b []byte) error {
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return err
+	}
+	f.Field, f.Op = values.Get("field"), values.Get("op")
+	return nil
+}
+
+type SearchForm struct {
+	Span   Span   `form:"span"`
+	Filter Filter `form:"filter"`
+}
+
+type NetworkForm struct {
+	Addr    netip.Addr    `form:"addr"`
+	Peers   []netip.Addr  `form:"peers,omitempty"`
+	Gateway *netip.Addr   `form:"gateway,omitempty"`
+	Level   Level         `form:"level"`
+	Limits  map[Level]int `form:"limits,omitempty"`
+	Version Version       `form:"version,omitempty"`
+}
+
 func diff[T any](a, b T) string {
-	if diff := cmp.Diff(a, b, cmpopts.EquateComparable(MyDate{})); diff != "" {
+	if diff := cmp.Diff(a, b, cmpopts.EquateComparable(MyDate{}, netip.Addr{})); diff != "" {
 		return fmt.Sprintf("(-want +got):\n%s", diff)
 	}
 	return ""
@@ -66,3 +214,16 @@ func pointerTo[T any](v T) *T {
 func valuesToBytes(values url.Values) []byte {
 	return []byte(values.Encode())
 }
+
+// pairsToBytes encodes alternating keys and values in the order given, rather
+// than sorted by key as valuesToBytes does.
+func pairsToBytes(kv ...string) []byte {
+	var b []byte
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b = append(b, '&')
+		}
+		b = append(b, url.QueryEscape(kv[i])+"="+url.QueryEscape(kv[i+1])...)
+	}
+	return b
+}
@@ -0,0 +1,114 @@
+package encoding
+
+import "strings"
+
+// A KeySyntax determines how the key of a nested value is written. Top-level
+// keys, such as the fields of a flat struct, are the same in every syntax.
+type KeySyntax int
+
+const (
+	// BracketSyntax nests keys in square brackets, as sent by browsers and
+	// understood by Rails and PHP: user[address][city] and items[0][sku].
+	BracketSyntax KeySyntax = iota
+
+	// DotSyntax separates nested keys with dots, as understood by Spring MVC
+	// and gorilla/schema: user.address.city and items.0.sku. When decoding,
+	// bracketed indices such as items[0].sku are accepted too.
+	DotSyntax
+)
+
+// WithKeySyntax sets the syntax of nested keys. The default is
+// [BracketSyntax].
+func WithKeySyntax(s KeySyntax) Option {
+	return func(o *options) {
+		o.keySyntax = s
+	}
+}
+
+// nest returns the key of name nested under prefix. An empty prefix denotes a
+// top-level key. An empty name denotes an element appended to a slice, which
+// is written as empty brackets or, in dot syntax, as a repeated key.
+func (s KeySyntax) nest(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if s == DotSyntax {
+		if name == "" {
+			return prefix
+		}
+		return prefix + "." + name
+	}
+	return prefix + "[" + name + "]"
+}
+
+// split splits key into its segments. A key that is not well formed is
+// returned as a single segment so that it can still match a field or map key
+// verbatim.
+func (s KeySyntax) split(key string) []string {
+	if s == DotSyntax {
+		return splitDots(key)
+	}
+	return splitBrackets(key)
+}
+
+// join is the inverse of split.
+func (s KeySyntax) join(segments []string) string {
+	key := segments[0]
+	for _, seg := range segments[1:] {
+		key = s.nest(key, seg)
+	}
+	return key
+}
+
+// splitBrackets splits a key such as user[address][city].
+func splitBrackets(key string) []string {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return []string{key}
+	}
+
+	segments := []string{key[:i]}
+	for rest := key[i:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return []string{key}
+		}
+		segments = append(segments, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return segments
+}
+
+// splitDots splits a key such as user.address.city or items[0].sku.
+func splitDots(key string) []string {
+	i := strings.IndexAny(key, ".[")
+	if i <= 0 {
+		return []string{key}
+	}
+
+	segments := []string{key[:i]}
+	for rest := key[i:]; rest != ""; {
+		var seg string
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			seg, rest = rest[1:end+1], rest[end+1:]
+			if seg == "" {
+				return []string{key}
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return []string{key}
+			}
+			seg, rest = rest[1:end], rest[end+1:]
+		default:
+			return []string{key}
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
@@ -0,0 +1,349 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// defaultMaxMemory is the number of bytes of a multipart body kept in memory,
+// unless changed with [WithMaxMemory]. It matches the default used by
+// [net/http.Request.ParseMultipartForm].
+const defaultMaxMemory = 32 << 20
+
+// WithMaxMemory sets the number of bytes of a multipart body kept in memory
+// when decoding. File parts beyond it are spilled to temporary files on disk.
+// The default is 32 MB.
+func WithMaxMemory(n int64) Option {
+	return func(o *options) {
+		o.maxMemory = n
+	}
+}
+
+// WithMaxDiskSize sets the number of bytes of a multipart body that may be
+// spilled to disk once the memory threshold is reached. A body larger than
+// both thresholds together is rejected. The default, zero, sets no limit.
+func WithMaxDiskSize(n int64) Option {
+	return func(o *options) {
+		o.maxDiskSize = n
+	}
+}
+
+var fileType = reflect.TypeFor[File]()
+
+// A File is a file uploaded in a multipart form. Struct fields and map values
+// of type File, *File and []*File receive the file parts decoded by a
+// [MultipartDecoder].
+type File struct {
+	// Filename is the name of the file as sent by the client.
+	Filename string
+
+	// Header is the MIME header of the part holding the file.
+	Header textproto.MIMEHeader
+
+	// Size is the length of the file in bytes.
+	Size int64
+
+	fh *multipart.FileHeader
+	r  io.Reader
+}
+
+// NewFile returns a file named filename whose content is read from r, for
+// encoding with a [MultipartEncoder].
+func NewFile(filename string, r io.Reader) *File {
+	return &File{Filename: filename, r: r}
+}
+
+// Open returns a reader for the content of the file. Files that did not fit
+// within the memory threshold of the decoder are read from disk. A file made
+// by [NewFile] returns its reader, so its content can be read only once.
+func (f *File) Open() (io.ReadCloser, error) {
+	switch {
+	case f.fh != nil:
+		return f.fh.Open()
+	case f.r != nil:
+		if rc, ok := f.r.(io.ReadCloser); ok {
+			return rc, nil
+		}
+		return io.NopCloser(f.r), nil
+	}
+	return nil, errors.New("form: file has no content")
+}
+
+var readerType = reflect.TypeFor[io.Reader]()
+
+// assertFile reports whether v is encoded as a file: either a [File] or any
+// value that implements [io.Reader]. A reader that has a name, such as an
+// [os.File], lends its base name to the file.
+func assertFile(v reflect.Value) (*File, bool) {
+	if v.Type() == fileType {
+		f := v.Interface().(File)
+		return &f, true
+	}
+
+	var r io.Reader
+	switch {
+	case v.CanAddr() && v.Addr().Type().Implements(readerType):
+		r = v.Addr().Interface().(io.Reader)
+	case v.Type().Implements(readerType):
+		r = v.Interface().(io.Reader)
+	default:
+		return nil, false
+	}
+
+	f := &File{r: r}
+	if n, ok := r.(interface{ Name() string }); ok {
+		f.Filename = filepath.Base(n.Name())
+	}
+	return f, true
+}
+
+// marshalFile writes f under key. Only a multipart destination can hold files.
+func marshalFile(e *encodeState, key string, f *File) error {
+	fw, ok := e.w.(fileWriter)
+	if !ok {
+		return fmt.Errorf("form: cannot url-encode file %s", key)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	filename := f.Filename
+	if filename == "" {
+		filename = key
+	}
+	return fw.writeFile(key, filename, f.Header, r)
+}
+
+// assignFile returns an assignFunc that assigns the uploaded file f.
+func assignFile(f *File) assignFunc {
+	return func(v reflect.Value) error {
+		if v.Type() != fileType {
+			return &UnmarshalTypeError{Value: f.Filename, Type: v.Type(), Err: errFileMismatch}
+		}
+		v.Set(reflect.ValueOf(*f))
+		return nil
+	}
+}
+
+// errFileMismatch is reported when a file part is found under the key of a
+// value that is not a [File].
+var errFileMismatch = errors.New("a file can only be decoded into a File")
+
+// A MultipartEncoder writes values as a multipart/form-data body. Scalar
+// values are written as form fields and [File] and [io.Reader] values as file
+// parts, under the same keys that [Marshal] would use.
+type MultipartEncoder struct {
+	mw   *multipart.Writer
+	opts options
+	err  error
+}
+
+// NewMultipartEncoder returns an encoder that writes a multipart body to w.
+func NewMultipartEncoder(w io.Writer, opts ...Option) *MultipartEncoder {
+	return &MultipartEncoder{mw: multipart.NewWriter(w), opts: newOptions(opts)}
+}
+
+// FormDataContentType returns the Content-Type of the body, including its
+// boundary.
+func (e *MultipartEncoder) FormDataContentType() string {
+	return e.mw.FormDataContentType()
+}
+
+// Encode writes the fields of the struct or map v as parts of the body. It may
+// be called more than once to add parts from several values, after which
+// [MultipartEncoder.Close] must be called to finish the body.
+func (e *MultipartEncoder) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer || !writesNestedKeys(rv.Type()) {
+		return fmt.Errorf("form: cannot encode %v as multipart form", reflect.TypeOf(v))
+	}
+
+	es := &encodeState{w: e, opts: e.opts}
+	if err := typeEncoder(rv.Type())(es, "", rv); err != nil {
+		return fmt.Errorf("form: failed to marshal: %w", err)
+	}
+	return e.err
+}
+
+// Close writes the trailing boundary of the body.
+func (e *MultipartEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.mw.Close()
+}
+
+func (e *MultipartEncoder) writePair(key, value string) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = e.mw.WriteField(key, value)
+	return e.err
+}
+
+func (e *MultipartEncoder) writeFile(key, filename string, header textproto.MIMEHeader, r io.Reader) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	h := make(textproto.MIMEHeader, len(header)+2)
+	for k, v := range header {
+		h[k] = v
+	}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(key), quoteEscaper.Replace(filename)))
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+
+	var w io.Writer
+	if w, e.err = e.mw.CreatePart(h); e.err != nil {
+		return e.err
+	}
+	_, e.err = io.Copy(w, r)
+	return e.err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// A MultipartDecoder reads and decodes a multipart/form-data body.
+type MultipartDecoder struct {
+	r        io.Reader
+	boundary string
+	opts     options
+	form     *multipart.Form
+}
+
+// NewMultipartDecoder returns a decoder that reads a multipart body from r.
+// The boundary may be given either on its own or as the full Content-Type
+// header of the body, from which it is taken.
+func NewMultipartDecoder(r io.Reader, boundary string, opts ...Option) *MultipartDecoder {
+	return &MultipartDecoder{r: r, boundary: boundary, opts: newOptions(opts)}
+}
+
+// Decode reads the multipart body and stores its values and files in the
+// struct or map pointed to by v. The body is read on the first call only, so
+// later calls decode the same form again.
+//
+// Files spilled to disk remain there until [MultipartDecoder.RemoveAll] is
+// called.
+func (d *MultipartDecoder) Decode(v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if !isCompositePointer(val) {
+		return fmt.Errorf("form: cannot decode multipart form into %v", val.Type())
+	}
+
+	form, err := d.readForm()
+	if err != nil {
+		return err
+	}
+
+	ds := &decodeState{seen: map[string]bool{}, opts: d.opts}
+	if err := unmarshalValues(ds, form.Value, val); err != nil {
+		return err
+	}
+	if err := unmarshalFiles(ds, form.File, val); err != nil {
+		return err
+	}
+	return ds.finish()
+}
+
+// RemoveAll removes any temporary files created while decoding.
+func (d *MultipartDecoder) RemoveAll() error {
+	if d.form == nil {
+		return nil
+	}
+	return d.form.RemoveAll()
+}
+
+func (d *MultipartDecoder) readForm() (*multipart.Form, error) {
+	if d.form != nil {
+		return d.form, nil
+	}
+
+	boundary, err := multipartBoundary(d.boundary)
+	if err != nil {
+		return nil, err
+	}
+
+	r := d.r
+	var lr *io.LimitedReader
+	if d.opts.maxDiskSize > 0 {
+		lr = &io.LimitedReader{R: r, N: d.opts.maxMemory + d.opts.maxDiskSize + 1}
+		r = lr
+	}
+
+	form, err := multipart.NewReader(r, boundary).ReadForm(d.opts.maxMemory)
+	if lr != nil && lr.N <= 0 {
+		if form != nil {
+			form.RemoveAll()
+		}
+		return nil, fmt.Errorf("form: multipart body exceeds %d bytes", d.opts.maxMemory+d.opts.maxDiskSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("form: invalid multipart form: %w", err)
+	}
+	d.form = form
+	return form, nil
+}
+
+// multipartBoundary returns the boundary parameter of s if it is a multipart
+// Content-Type header, or s itself otherwise.
+func multipartBoundary(s string) (string, error) {
+	mediatype, params, err := mime.ParseMediaType(s)
+	if err != nil || !strings.HasPrefix(mediatype, "multipart/") {
+		return s, nil
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return "", fmt.Errorf("form: no multipart boundary in %q", s)
+	}
+	return boundary, nil
+}
+
+func unmarshalFiles(d *decodeState, files map[string][]*multipart.FileHeader, v reflect.Value) error {
+	rv := reflect.Indirect(v)
+
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		d.key = key
+		segments := d.opts.keySyntax.split(key)
+		for _, fh := range files[key] {
+			f := &File{
+				Filename: fh.Filename,
+				Header:   fh.Header,
+				Size:     fh.Size,
+				fh:       fh,
+			}
+			if err := unmarshalPath(d, rv, "", segments, assignFile(f)); err != nil {
+				if err := d.addError(err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
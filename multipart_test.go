@@ -0,0 +1,399 @@
+package encoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/tomasbasham/encoding"
+)
+
+type UploadForm struct {
+	Title       string           `form:"title"`
+	Tags        []string         `form:"tags"`
+	Avatar      *encoding.File   `form:"avatar"`
+	Attachments []*encoding.File `form:"attachments"`
+}
+
+type part struct {
+	name     string
+	filename string
+	content  string
+}
+
+func multipartBody(t testing.TB, parts ...part) (*bytes.Buffer, *multipart.Writer) {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, p := range parts {
+		var pw io.Writer
+		var err error
+		if p.filename != "" {
+			pw, err = w.CreateFormFile(p.name, p.filename)
+		} else {
+			pw, err = w.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatalf("failed to create part: %v", err)
+		}
+		io.WriteString(pw, p.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	return &b, w
+}
+
+func readFile(t testing.TB, f *encoding.File) string {
+	t.Helper()
+
+	r, err := f.Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return string(b)
+}
+
+func TestMultipartDecoder(t *testing.T) {
+	t.Parallel()
+
+	parts := []part{
+		{name: "title", content: "holiday"},
+		{name: "tags", content: "beach"},
+		{name: "tags", content: "sun"},
+		{name: "avatar", filename: "me.png", content: "png data"},
+		{name: "attachments", filename: "a.txt", content: "first"},
+		{name: "attachments", filename: "b.txt", content: strings.Repeat("x", 1024)},
+	}
+
+	tests := []struct {
+		name     string
+		boundary func(w *multipart.Writer) string
+		opts     []encoding.Option
+	}{
+		{
+			name:     "boundary",
+			boundary: (*multipart.Writer).Boundary,
+		},
+		{
+			name:     "content type",
+			boundary: (*multipart.Writer).FormDataContentType,
+		},
+		{
+			name:     "files spilled to disk",
+			boundary: (*multipart.Writer).Boundary,
+			opts:     []encoding.Option{encoding.WithMaxMemory(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body, w := multipartBody(t, parts...)
+			decoder := encoding.NewMultipartDecoder(body, tt.boundary(w), tt.opts...)
+			defer decoder.RemoveAll()
+
+			var got UploadForm
+			if err := decoder.Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if diff := diff("holiday", got.Title); diff != "" {
+				t.Errorf("Decode() title mismatch %s", diff)
+			}
+			if diff := diff([]string{"beach", "sun"}, got.Tags); diff != "" {
+				t.Errorf("Decode() tags mismatch %s", diff)
+			}
+			if got.Avatar == nil {
+				t.Fatal("Decode() avatar = nil")
+			}
+			if diff := diff("me.png", got.Avatar.Filename); diff != "" {
+				t.Errorf("Decode() avatar filename mismatch %s", diff)
+			}
+			if diff := diff("png data", readFile(t, got.Avatar)); diff != "" {
+				t.Errorf("Decode() avatar content mismatch %s", diff)
+			}
+			if len(got.Attachments) != 2 {
+				t.Fatalf("Decode() attachments = %d, want 2", len(got.Attachments))
+			}
+			if diff := diff("first", readFile(t, got.Attachments[0])); diff != "" {
+				t.Errorf("Decode() attachment content mismatch %s", diff)
+			}
+			if diff := diff(int64(1024), got.Attachments[1].Size); diff != "" {
+				t.Errorf("Decode() attachment size mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestMultipartDecoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		boundary string
+		opts     []encoding.Option
+		target   any
+	}{
+		{
+			name:   "non-pointer target",
+			target: UploadForm{},
+		},
+		{
+			name:   "primitive target",
+			target: new(string),
+		},
+		{
+			name:     "content type without boundary",
+			boundary: "multipart/form-data",
+			target:   &UploadForm{},
+		},
+		{
+			name:     "wrong boundary",
+			boundary: "not-the-boundary",
+			target:   &UploadForm{},
+		},
+		{
+			name: "file into string",
+			target: &struct {
+				Avatar string `form:"avatar"`
+			}{},
+		},
+		{
+			name: "file into byte slice",
+			target: &struct {
+				Avatar []byte `form:"avatar"`
+			}{},
+		},
+		{
+			name: "body exceeds disk size",
+			opts: []encoding.Option{
+				encoding.WithMaxMemory(16),
+				encoding.WithMaxDiskSize(16),
+			},
+			target: &UploadForm{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body, w := multipartBody(t, part{name: "avatar", filename: "me.png", content: strings.Repeat("x", 1024)})
+			boundary := tt.boundary
+			if boundary == "" {
+				boundary = w.Boundary()
+			}
+
+			decoder := encoding.NewMultipartDecoder(body, boundary, tt.opts...)
+			defer decoder.RemoveAll()
+
+			if err := decoder.Decode(tt.target); err == nil {
+				t.Error("Decode() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestMultipartDecoder_FileMismatch(t *testing.T) {
+	t.Parallel()
+
+	body, w := multipartBody(t,
+		part{name: "title", content: "holiday"},
+		part{name: "avatar", filename: "me.png", content: "png data"},
+	)
+	decoder := encoding.NewMultipartDecoder(body, w.Boundary())
+	defer decoder.RemoveAll()
+
+	var got struct {
+		Title  string `form:"title"`
+		Avatar string `form:"avatar"`
+	}
+	err := decoder.Decode(&got)
+	var typeErr *encoding.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("Decode() error = %v, want *UnmarshalTypeError", err)
+	}
+	if diff := diff("avatar", typeErr.Field); diff != "" {
+		t.Errorf("UnmarshalTypeError.Field mismatch %s", diff)
+	}
+	if diff := diff("me.png", typeErr.Value); diff != "" {
+		t.Errorf("UnmarshalTypeError.Value mismatch %s", diff)
+	}
+}
+
+type ReportUpload struct {
+	Name    string            `form:"name"`
+	Data    io.Reader         `form:"data"`
+	Address Address           `form:"address"`
+	Labels  map[string]string `form:"labels,omitempty"`
+}
+
+func TestMultipartEncoder(t *testing.T) {
+	t.Parallel()
+
+	input := UploadForm{
+		Title:  "holiday",
+		Tags:   []string{"beach", "sun"},
+		Avatar: encoding.NewFile("me.png", strings.NewReader("png data")),
+		Attachments: []*encoding.File{
+			encoding.NewFile("a.txt", strings.NewReader("first")),
+			encoding.NewFile("b.txt", strings.NewReader("second")),
+		},
+	}
+
+	var b bytes.Buffer
+	encoder := encoding.NewMultipartEncoder(&b)
+	if err := encoder.Encode(&input); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	decoder := encoding.NewMultipartDecoder(&b, encoder.FormDataContentType())
+	defer decoder.RemoveAll()
+
+	var got UploadForm
+	if err := decoder.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if diff := diff(input.Title, got.Title); diff != "" {
+		t.Errorf("Encode() title mismatch %s", diff)
+	}
+	if diff := diff(input.Tags, got.Tags); diff != "" {
+		t.Errorf("Encode() tags mismatch %s", diff)
+	}
+	if diff := diff("me.png", got.Avatar.Filename); diff != "" {
+		t.Errorf("Encode() avatar filename mismatch %s", diff)
+	}
+	if diff := diff("png data", readFile(t, got.Avatar)); diff != "" {
+		t.Errorf("Encode() avatar content mismatch %s", diff)
+	}
+	if len(got.Attachments) != 2 {
+		t.Fatalf("Encode() attachments = %d, want 2", len(got.Attachments))
+	}
+	if diff := diff("second", readFile(t, got.Attachments[1])); diff != "" {
+		t.Errorf("Encode() attachment content mismatch %s", diff)
+	}
+}
+
+func TestMultipartEncoder_Parts(t *testing.T) {
+	t.Parallel()
+
+	type partInfo struct {
+		Name     string
+		Filename string
+		Content  string
+	}
+
+	tests := []struct {
+		name  string
+		input ReportUpload
+		want  []partInfo
+	}{
+		{
+			name: "reader",
+			input: ReportUpload{
+				Name:    "q1",
+				Data:    strings.NewReader("a,b,c"),
+				Address: Address{City: "London"},
+			},
+			want: []partInfo{
+				{Name: "name", Content: "q1"},
+				{Name: "data", Filename: "data", Content: "a,b,c"},
+				{Name: "address[city]", Content: "London"},
+				{Name: "address[zip]", Content: ""},
+			},
+		},
+		{
+			name: "nil reader",
+			input: ReportUpload{
+				Name:    "q1",
+				Address: Address{City: "London"},
+			},
+			want: []partInfo{
+				{Name: "name", Content: "q1"},
+				{Name: "address[city]", Content: "London"},
+				{Name: "address[zip]", Content: ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b bytes.Buffer
+			encoder := encoding.NewMultipartEncoder(&b)
+			if err := encoder.Encode(tt.input); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			_, params, _ := mime.ParseMediaType(encoder.FormDataContentType())
+			r := multipart.NewReader(&b, params["boundary"])
+
+			var got []partInfo
+			for {
+				p, err := r.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("NextPart() error = %v", err)
+				}
+				content, _ := io.ReadAll(p)
+				got = append(got, partInfo{Name: p.FormName(), Filename: p.FileName(), Content: string(content)})
+			}
+
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Encode() parts mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestMultipartEncoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input any
+	}{
+		{
+			name:  "primitive",
+			input: "hello",
+		},
+		{
+			name:  "slice",
+			input: []string{"a"},
+		},
+		{
+			name:  "nil",
+			input: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			encoder := encoding.NewMultipartEncoder(io.Discard)
+			if err := encoder.Encode(tt.input); err == nil {
+				t.Error("Encode() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package encoding
+
+// defaultMaxSliceIndex is the largest slice index accepted when decoding,
+// unless changed with [WithMaxSliceIndex].
+const defaultMaxSliceIndex = 1000
+
+// defaultFlushThreshold is the number of bytes an [Encoder] buffers before
+// writing them to its stream, unless changed with [WithFlushThreshold].
+const defaultFlushThreshold = 4096
+
+// An Option configures how [Marshal], [Unmarshal], [Encoder] and [Decoder]
+// encode and decode values. Options that only affect one direction are
+// ignored by the other.
+type Option func(*options)
+
+type options struct {
+	keySyntax             KeySyntax
+	sliceStyle            SliceStyle
+	maxSliceIndex         int
+	maxMemory             int64
+	maxDiskSize           int64
+	maxBodySize           int64
+	maxKeys               int
+	maxValueLength        int
+	allErrors             bool
+	disallowUnknownFields bool
+	flushThreshold        int
+	sortKeys              bool
+	mapKeyOrder           func(a, b string) int
+	queryPrecedence       QueryPrecedence
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	o.init(opts)
+	return o
+}
+
+// init sets o to the defaults and then applies opts. Initialising options in
+// place avoids allocating them when they are already on the heap.
+func (o *options) init(opts []Option) {
+	*o = options{
+		maxSliceIndex:  defaultMaxSliceIndex,
+		maxMemory:      defaultMaxMemory,
+		flushThreshold: defaultFlushThreshold,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// A SliceStyle determines how the elements of a slice of scalar values are
+// keyed when encoding. Slices of structs and maps are always indexed, as in
+// children[0][name]. Decoding accepts every style regardless.
+type SliceStyle int
+
+const (
+	// RepeatedKeys writes every element under the key of the slice itself:
+	// tags=a&tags=b.
+	RepeatedKeys SliceStyle = iota
+
+	// EmptyBrackets appends empty brackets to the key of the slice, as
+	// expected by Rails and PHP: tags[]=a&tags[]=b. In [DotSyntax], which has
+	// no empty brackets, keys are repeated instead.
+	EmptyBrackets
+
+	// IndexedKeys writes the index of every element: tags[0]=a&tags[1]=b.
+	IndexedKeys
+)
+
+// WithSliceStyle sets the style used to key the elements of scalar slices.
+// The default is [RepeatedKeys].
+func WithSliceStyle(s SliceStyle) Option {
+	return func(o *options) {
+		o.sliceStyle = s
+	}
+}
+
+// WithMaxSliceIndex sets the largest slice index accepted when decoding. An
+// explicit index beyond it is an error. This bounds the memory a single key
+// such as items[99999999] can cause to be allocated. Elements appended by
+// repeated keys or empty brackets grow a slice one at a time, and are bounded
+// by [WithMaxKeys] instead. The default is 1000.
+func WithMaxSliceIndex(n int) Option {
+	return func(o *options) {
+		o.maxSliceIndex = n
+	}
+}
+
+// WithAllErrors makes decoding carry on past a key whose value cannot be
+// decoded, so that every such key is reported at once in a [FieldErrors]
+// rather than only the first. Keys that decode successfully are assigned
+// either way.
+func WithAllErrors() Option {
+	return func(o *options) {
+		o.allErrors = true
+	}
+}
+
+// WithDisallowUnknownFields makes decoding fail with an [UnknownFieldsError]
+// when the input has keys that do not match any field of the destination,
+// including keys of ignored fields and keys nested below values that do not
+// take nested keys. The rest of the input is still decoded so that every
+// unknown key can be reported.
+func WithDisallowUnknownFields() Option {
+	return func(o *options) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// WithMaxBodySize sets the largest number of bytes of form data that are read
+// when decoding. Reading stops with a [LimitError] as soon as it is exceeded.
+// The default, zero, is no limit.
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+// WithMaxKeys sets the largest number of key-value pairs accepted when
+// decoding, counting every value of a repeated key. Decoding stops with a
+// [LimitError] at the first pair beyond it. The default, zero, is no limit.
+func WithMaxKeys(n int) Option {
+	return func(o *options) {
+		o.maxKeys = n
+	}
+}
+
+// WithMaxValueLength sets the largest number of bytes, before unescaping, of
+// any single key or value accepted when decoding. Decoding stops with a
+// [LimitError] as soon as it is exceeded. The default, zero, is no limit.
+func WithMaxValueLength(n int) Option {
+	return func(o *options) {
+		o.maxValueLength = n
+	}
+}
+
+// WithFlushThreshold sets the number of bytes an [Encoder] buffers before
+// writing them to its stream. A threshold of zero writes every pair as soon as
+// it is encoded. The default is 4096.
+func WithFlushThreshold(n int) Option {
+	return func(o *options) {
+		o.flushThreshold = n
+	}
+}
+
+// WithSortKeys sorts encoded pairs by key, as [url.Values.Encode] does, rather
+// than writing struct fields in declaration order. Values of the same key keep
+// their order. This gives a canonical encoding, such as for signing a request.
+func WithSortKeys() Option {
+	return func(o *options) {
+		o.sortKeys = true
+	}
+}
+
+// WithMapKeyOrder sets the order in which the entries of a map are encoded.
+// The function reports whether a sorts before b, as for [slices.SortFunc]:
+// negative if a comes first, positive if b does. The default is key order.
+func WithMapKeyOrder(cmp func(a, b string) int) Option {
+	return func(o *options) {
+		o.mapKeyOrder = cmp
+	}
+}
@@ -0,0 +1,234 @@
+package encoding
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// A LimitError is returned when form data exceeds one of the limits set with
+// [WithMaxBodySize], [WithMaxKeys] or [WithMaxValueLength].
+type LimitError struct {
+	Limit  string // the limit exceeded: "body size", "keys" or "value length"
+	Max    int64  // the value of the limit
+	Offset int64  // the input byte offset at which it was exceeded
+}
+
+func (e *LimitError) Error() string {
+	return "form: exceeded maximum " + e.Limit + " of " + strconv.FormatInt(e.Max, 10) +
+		" at offset " + strconv.FormatInt(e.Offset, 10)
+}
+
+var errSemicolon = errors.New("invalid semicolon separator in query")
+
+// A Token is a single key-value pair of form data, as returned by
+// [Decoder.Token]. The key and value are unescaped.
+type Token struct {
+	Key    string
+	Value  string
+	Offset int64 // the input byte offset at which the pair starts
+}
+
+// A scanner splits form data into key-value pairs as it is read. Only the pair
+// being scanned is buffered, so a body can be decoded as it arrives and a
+// limit is reported as soon as it is exceeded rather than after the whole
+// body has been read.
+//
+// In valuesOnly mode the input is a list of values, as in 1&2&3, and equals
+// signs and semicolons are taken literally.
+type scanner struct {
+	r          io.ByteScanner
+	offset     int64
+	pairs      int
+	valuesOnly bool
+
+	maxBodySize    int64
+	maxKeys        int
+	maxValueLength int
+
+	key, value []byte
+	err        error
+}
+
+func newScanner(r io.Reader, opts *options) *scanner {
+	br, ok := r.(io.ByteScanner)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &scanner{
+		r:              br,
+		maxBodySize:    opts.maxBodySize,
+		maxKeys:        opts.maxKeys,
+		maxValueLength: opts.maxValueLength,
+	}
+}
+
+// next returns the next pair in the input. Empty pairs, as in a&&b, are
+// skipped. At the end of the input it returns io.EOF, and after any other
+// error it keeps returning the same error.
+func (s *scanner) next() (Token, error) {
+	if s.err != nil {
+		return Token{}, s.err
+	}
+	p, err := s.scan()
+	if err != nil {
+		s.err = err
+		return Token{}, err
+	}
+	return p, nil
+}
+
+// more reports whether there is another pair in the input, reading ahead past
+// any empty pairs to find out. It reports false after an error, which the
+// next call to next returns.
+func (s *scanner) more() bool {
+	for s.err == nil {
+		c, err := s.readByte()
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if c != '&' {
+			s.r.UnreadByte()
+			s.offset--
+			return true
+		}
+	}
+	return false
+}
+
+// readAll returns the rest of the input without splitting it into pairs, for
+// values that decode themselves. The input is neither unescaped nor checked,
+// but the limits on the number of pairs and the length of each key and value
+// apply to it as they would to pairs.
+func (s *scanner) readAll() ([]byte, error) {
+	var (
+		b       []byte
+		n       int  // the length of the key or value being read
+		inPair  bool // whether the pair being read is non-empty
+		inValue bool
+	)
+	for {
+		c, err := s.readByte()
+		if err != nil && err != io.EOF {
+			s.err = err
+			return nil, err
+		}
+		if err == io.EOF || c == '&' {
+			if inPair {
+				s.pairs++
+				if s.maxKeys > 0 && s.pairs > s.maxKeys {
+					s.err = s.limitError("keys", int64(s.maxKeys))
+					return nil, s.err
+				}
+			}
+			if err == io.EOF {
+				return b, nil
+			}
+			b = append(b, c)
+			n, inPair, inValue = 0, false, false
+			continue
+		}
+
+		b = append(b, c)
+		inPair = true
+		if c == '=' && !inValue {
+			n, inValue = 0, true
+			continue
+		}
+		if n++; s.maxValueLength > 0 && n > s.maxValueLength {
+			s.err = s.limitError("value length", int64(s.maxValueLength))
+			return nil, s.err
+		}
+	}
+}
+
+func (s *scanner) scan() (Token, error) {
+	var (
+		start     = s.offset
+		inValue   = s.valuesOnly
+		semicolon = false
+	)
+	s.key, s.value = s.key[:0], s.value[:0]
+
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			if s.offset == start {
+				return Token{}, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return Token{}, err
+		}
+
+		if c == '&' {
+			if s.offset-1 == start {
+				// An empty pair: start again after the separator.
+				start = s.offset
+				continue
+			}
+			break
+		}
+		if c == ';' && !s.valuesOnly {
+			semicolon = true
+		}
+		if c == '=' && !inValue {
+			inValue = true
+			continue
+		}
+
+		if inValue {
+			s.value = append(s.value, c)
+			if s.maxValueLength > 0 && len(s.value) > s.maxValueLength {
+				return Token{}, s.limitError("value length", int64(s.maxValueLength))
+			}
+		} else {
+			s.key = append(s.key, c)
+			if s.maxValueLength > 0 && len(s.key) > s.maxValueLength {
+				return Token{}, s.limitError("value length", int64(s.maxValueLength))
+			}
+		}
+	}
+
+	if semicolon {
+		return Token{}, fmt.Errorf("form: invalid form data: %w", errSemicolon)
+	}
+	s.pairs++
+	if s.maxKeys > 0 && s.pairs > s.maxKeys {
+		return Token{}, s.limitError("keys", int64(s.maxKeys))
+	}
+
+	key, err := url.QueryUnescape(string(s.key))
+	if err != nil {
+		return Token{}, fmt.Errorf("form: invalid form data: %w", err)
+	}
+	value, err := url.QueryUnescape(string(s.value))
+	if err != nil {
+		return Token{}, fmt.Errorf("form: invalid form data: %w", err)
+	}
+	return Token{Key: key, Value: value, Offset: start}, nil
+}
+
+func (s *scanner) readByte() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("form: failed to read body: %w", err)
+		}
+		return 0, err
+	}
+	s.offset++
+	if s.maxBodySize > 0 && s.offset > s.maxBodySize {
+		return 0, s.limitError("body size", s.maxBodySize)
+	}
+	return c, nil
+}
+
+func (s *scanner) limitError(limit string, max int64) error {
+	return &LimitError{Limit: limit, Max: max, Offset: s.offset - 1}
+}
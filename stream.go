@@ -1,41 +1,252 @@
 package encoding
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"iter"
+	"reflect"
 )
 
+// A Decoder reads and decodes form data from an input stream. Pairs are
+// decoded as they are read, so the body is never buffered in full.
 type Decoder struct {
-	r io.Reader
+	scan *scanner
+	opts options
 }
 
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: r}
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{opts: newOptions(opts)}
+	d.scan = newScanner(r, &d.opts)
+	return d
 }
 
+// DisallowUnknownFields causes the Decoder to return an error when the
+// destination is a struct and the input contains keys which do not match any
+// non-ignored, exported fields in the destination.
+func (d *Decoder) DisallowUnknownFields() {
+	d.opts.disallowUnknownFields = true
+}
+
+// Decode reads the rest of the form data from its input and stores it in the
+// value pointed to by v. See the documentation for [Unmarshal] for details
+// about the conversion of form data into a Go value.
 func (d *Decoder) Decode(v any) error {
-	body, err := io.ReadAll(d.r)
-	if err != nil {
-		return fmt.Errorf("form: failed to read body: %w", err)
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
+	if u, ok := v.(Unmarshaler); ok {
+		return unmarshalSelf(d.scan, u)
+	}
+
+	ds := &decodeState{seen: map[string]bool{}, opts: d.opts}
+	return unmarshal(ds, d.scan, val)
+}
 
-	return Unmarshal(body, v)
+// Token returns the next key-value pair in the input stream, unescaped, in
+// the order it appears. At the end of the input, Token returns a zero Token and
+// [io.EOF].
+//
+// Token reads the same pairs that [Decoder.Decode] would, and applies the same
+// limits to them. A pair returned by Token is not decoded, so the two can be
+// mixed to skip or inspect pairs before decoding the rest.
+func (d *Decoder) Token() (Token, error) {
+	return d.scan.next()
 }
 
+// More reports whether there is another pair in the input stream.
+func (d *Decoder) More() bool {
+	return d.scan.more()
+}
+
+// InputOffset returns the input stream byte offset of the current decoder
+// position, just past the most recently returned pair and its separator.
+func (d *Decoder) InputOffset() int64 {
+	return d.scan.offset
+}
+
+// Pairs returns an iterator over the remaining key-value pairs in the input
+// stream, unescaped, in the order they appear and including duplicates. Each
+// pair is read only when the loop asks for it, so breaking out of the loop
+// stops reading. If reading fails the iteration ends early, and the error is
+// reported by [Decoder.Err].
+func (d *Decoder) Pairs() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for {
+			tok, err := d.scan.next()
+			if err != nil {
+				return
+			}
+			if !yield(tok.Key, tok.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error, other than [io.EOF], encountered while reading
+// the input stream.
+func (d *Decoder) Err() error {
+	if d.scan.err == io.EOF {
+		return nil
+	}
+	return d.scan.err
+}
+
+// Pairs returns an iterator over the key-value pairs of the form data read
+// from r. See [Decoder.Pairs] for details.
+//
+// Malformed input, a failed read and a [LimitError] all end the iteration
+// early, without an error. Where that must be told apart from the end of the
+// input, iterate over [Decoder.Pairs] and check [Decoder.Err] afterwards:
+//
+//	d := encoding.NewDecoder(r)
+//	for k, v := range d.Pairs() {
+//		// ...
+//	}
+//	if err := d.Err(); err != nil {
+//		// ...
+//	}
+func Pairs(r io.Reader, opts ...Option) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		NewDecoder(r, opts...).Pairs()(yield)
+	}
+}
+
+// PairsBytes is like [Pairs] but iterates over the form data in data. Unlike
+// an iterator over a reader, it can be used more than once.
+func PairsBytes(data []byte, opts ...Option) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		NewDecoder(bytes.NewReader(data), opts...).Pairs()(yield)
+	}
+}
+
+// An Encoder writes form data to an output stream. Pairs are escaped and
+// written as the value is walked, in the order they are encoded, rather than
+// collected and sorted first, unless [WithSortKeys] is given. Output is
+// buffered and written to the stream whenever the flush threshold is reached,
+// and at the end of every call to [Encoder.Encode].
+//
+// Every value encoded, and every pair written with [Encoder.WritePair], adds
+// to the same body.
 type Encoder struct {
-	w io.Writer
+	w    io.Writer
+	opts options
+
+	// b holds the pairs not yet written to w. Its buffer is reused once they
+	// have been, as is the state used to walk each value.
+	b   bufferWriter
+	es  encodeState
+	err error
+
+	// flushes counts the writes to w, so that Encode can tell whether the
+	// pairs of a value that failed to encode are all still buffered.
+	flushes int
 }
 
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{w: w, opts: newOptions(opts)}
+	e.es = encodeState{w: e, opts: e.opts}
+	return e
 }
 
+// Encode writes the form encoding of v to the stream. See the documentation
+// for [Marshal] for details about the conversion of Go values to form data.
+//
+// If v cannot be encoded, the pairs buffered from it are discarded rather
+// than written with the next value. Pairs already flushed to the stream, when
+// v is larger than the flush threshold, cannot be taken back.
 func (e *Encoder) Encode(v any) error {
-	data, err := Marshal(v)
+	if e.err != nil {
+		return e.err
+	}
+
+	mark, started, flushes := len(e.b.buf), e.b.started, e.flushes
+	es := &e.es
+	if m, ok := asMarshaler(v); ok {
+		e.b.valuesOnly = false
+		if err := marshalForm(es, m); err != nil {
+			e.rewind(mark, started, flushes)
+			return err
+		}
+		return e.Flush()
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer {
+		return nil
+	}
+
+	var err error
+	switch {
+	case !writesNestedKeys(rv.Type()):
+		e.b.valuesOnly = true
+		err = typeEncoder(rv.Type())(es, "", rv)
+	case e.opts.sortKeys:
+		e.b.valuesOnly = false
+		err = marshalSorted(es, rv)
+	default:
+		e.b.valuesOnly = false
+		err = typeEncoder(rv.Type())(es, "", rv)
+	}
 	if err != nil {
+		if e.err != nil {
+			return e.err
+		}
+		e.rewind(mark, started, flushes)
+		return fmt.Errorf("form: failed to marshal: %w", err)
+	}
+	return e.Flush()
+}
+
+// rewind discards the pairs buffered since the buffer was mark bytes long. If
+// it has been flushed since, only the pairs buffered after that are discarded.
+func (e *Encoder) rewind(mark int, started bool, flushes int) {
+	if e.flushes != flushes {
+		e.b.buf = e.b.buf[:0]
+		return
+	}
+	e.b.buf, e.b.started = e.b.buf[:mark], started
+}
+
+// WritePair escapes and writes a single key-value pair to the stream. It is
+// buffered like any other pair, so [Encoder.Flush] must be called once the
+// body is complete.
+func (e *Encoder) WritePair(key, value string) error {
+	e.b.valuesOnly = false
+	return e.writePair(key, value)
+}
+
+// Flush writes any buffered pairs to the stream.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.b.buf) == 0 {
+		return nil
+	}
+	if _, err := e.w.Write(e.b.buf); err != nil {
+		e.err = err
 		return err
 	}
+	e.flushes++
+	e.b.buf = e.b.buf[:0]
+	return nil
+}
 
-	_, err = e.w.Write(data)
-	return err
+func (e *Encoder) writePair(key, value string) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.b.writePair(key, value)
+	if len(e.b.buf) >= e.opts.flushThreshold {
+		return e.Flush()
+	}
+	return nil
 }
@@ -2,7 +2,8 @@ package encoding_test
 
 import (
 	"bytes"
-	"net/url"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -15,6 +16,7 @@ func TestDecoder(t *testing.T) {
 	tests := []struct {
 		name    string
 		input   string
+		opts    []encoding.Option
 		target  any
 		want    any
 		wantErr bool
@@ -29,18 +31,47 @@ func TestDecoder(t *testing.T) {
 				Aliases: []string{"johnny", "jonny"},
 			},
 		},
+		{
+			name:   "dot syntax",
+			input:  "name=john&address.city=London",
+			opts:   []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			target: &NestedForm{},
+			want: &NestedForm{
+				Name:    "john",
+				Address: Address{City: "London"},
+			},
+		},
 		{
 			name:    "invalid query string",
 			input:   "%%%",
 			target:  &BasicForm{},
 			wantErr: true,
 		},
+		{
+			name:    "semicolon separator",
+			input:   "name=john;age=20",
+			target:  &BasicForm{},
+			wantErr: true,
+		},
+		{
+			name:   "empty pairs",
+			input:  "&name=john&&age=20&",
+			target: &BasicForm{},
+			want:   &BasicForm{Name: "john", Age: 20},
+		},
+		{
+			name:   "within limits",
+			input:  "name=john&age=20",
+			opts:   []encoding.Option{encoding.WithMaxBodySize(16), encoding.WithMaxKeys(2), encoding.WithMaxValueLength(4)},
+			target: &BasicForm{},
+			want:   &BasicForm{Name: "john", Age: 20},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			decoder := encoding.NewDecoder(strings.NewReader(tt.input))
+			decoder := encoding.NewDecoder(strings.NewReader(tt.input), tt.opts...)
 			err := decoder.Decode(tt.target)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Decode() error = %v, wantErr %v", err, tt.wantErr)
@@ -55,12 +86,524 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestDecoder_DisallowUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		target   any
+		wantKeys []string
+	}{
+		{
+			name:   "known fields",
+			input:  "name=john&address[city]=London&metadata[anything]=x",
+			target: &NestedForm{},
+		},
+		{
+			name:     "unknown top-level keys",
+			input:    "name=john&email=a&phone=b&phone=c",
+			target:   &NestedForm{},
+			wantKeys: []string{"email", "phone"},
+		},
+		{
+			name:     "unknown nested keys",
+			input:    "address[city]=London&address[country]=UK&billing[street]=x",
+			target:   &NestedForm{},
+			wantKeys: []string{"address[country]", "billing[street]"},
+		},
+		{
+			name:  "keys below scalar fields",
+			input: "name[first]=john&items[0][sku][code]=x",
+			target: &struct {
+				Name  string `form:"name"`
+				Items []Item `form:"items"`
+			}{},
+			wantKeys: []string{"name[first]", "items[0][sku][code]"},
+		},
+		{
+			name:     "invalid slice index",
+			input:    "items[x][sku]=a",
+			target:   &OrderForm{},
+			wantKeys: []string{"items[x][sku]"},
+		},
+		{
+			name:     "ignored fields",
+			input:    "public=a&Private=b&Ignored=c",
+			target:   &IgnoredFieldsForm{},
+			wantKeys: []string{"Private", "Ignored"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := encoding.NewDecoder(strings.NewReader(tt.input))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(tt.target)
+			if tt.wantKeys == nil {
+				if err != nil {
+					t.Errorf("Decode() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var unknownErr *encoding.UnknownFieldsError
+			if !errors.As(err, &unknownErr) {
+				t.Fatalf("Decode() error = %v, want *UnknownFieldsError", err)
+			}
+			if diff := diff(tt.wantKeys, unknownErr.Keys); diff != "" {
+				t.Errorf("UnknownFieldsError.Keys mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     io.Reader
+		opts      []encoding.Option
+		target    any
+		wantLimit string
+	}{
+		{
+			name:      "body size",
+			input:     strings.NewReader("name=john&age=20"),
+			opts:      []encoding.Option{encoding.WithMaxBodySize(15)},
+			wantLimit: "body size",
+		},
+		{
+			name:      "unbounded body",
+			input:     infiniteReader{},
+			opts:      []encoding.Option{encoding.WithMaxBodySize(1 << 10)},
+			wantLimit: "body size",
+		},
+		{
+			name:      "keys",
+			input:     strings.NewReader("aliases=a&aliases=b&aliases=c"),
+			opts:      []encoding.Option{encoding.WithMaxKeys(2)},
+			wantLimit: "keys",
+		},
+		{
+			name:      "value length",
+			input:     strings.NewReader("name=johnny"),
+			opts:      []encoding.Option{encoding.WithMaxValueLength(4)},
+			wantLimit: "value length",
+		},
+		{
+			name:      "key length",
+			input:     strings.NewReader("aliases=a"),
+			opts:      []encoding.Option{encoding.WithMaxValueLength(4)},
+			wantLimit: "value length",
+		},
+		{
+			name:      "values keys",
+			input:     strings.NewReader("a=1&b=2&c=3"),
+			opts:      []encoding.Option{encoding.WithMaxKeys(2)},
+			target:    &encoding.Values{},
+			wantLimit: "keys",
+		},
+		{
+			name:      "values value length",
+			input:     strings.NewReader("a=12345"),
+			opts:      []encoding.Option{encoding.WithMaxValueLength(4)},
+			target:    &encoding.Values{},
+			wantLimit: "value length",
+		},
+		{
+			name:      "unmarshaler keys",
+			input:     strings.NewReader("field=name&&op=eq&x=1"),
+			opts:      []encoding.Option{encoding.WithMaxKeys(2)},
+			target:    &Filter{},
+			wantLimit: "keys",
+		},
+		{
+			name:      "unmarshaler value length",
+			input:     strings.NewReader("field=name&op=equals"),
+			opts:      []encoding.Option{encoding.WithMaxValueLength(4)},
+			target:    &Filter{},
+			wantLimit: "value length",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			target := tt.target
+			if target == nil {
+				target = &BasicForm{}
+			}
+			err := encoding.NewDecoder(tt.input, tt.opts...).Decode(target)
+			var limitErr *encoding.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Decode() error = %v, want *LimitError", err)
+			}
+			if limitErr.Limit != tt.wantLimit {
+				t.Errorf("LimitError.Limit = %q, want %q", limitErr.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+// infiniteReader is a body that never ends.
+type infiniteReader struct{}
+
+func (infiniteReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+func TestDecoder_UnmarshalerWithinLimits(t *testing.T) {
+	t.Parallel()
+
+	opts := []encoding.Option{encoding.WithMaxKeys(2), encoding.WithMaxValueLength(5)}
+
+	var values encoding.Values
+	if err := encoding.NewDecoder(strings.NewReader("a=1&&b=2"), opts...).Decode(&values); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if diff := diff(encoding.Values{{"a", "1"}, {"b", "2"}}, values); diff != "" {
+		t.Errorf("Decode() mismatch %s", diff)
+	}
+
+	var filter Filter
+	if err := encoding.NewDecoder(strings.NewReader("field=name&op=eq"), opts...).Decode(&filter); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if diff := diff(Filter{Field: "name", Op: "eq"}, filter); diff != "" {
+		t.Errorf("Decode() mismatch %s", diff)
+	}
+}
+
+func TestDecoder_Token(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    []encoding.Option
+		want    []encoding.Token
+		wantErr bool
+	}{
+		{
+			name:  "wire order",
+			input: "b=2&a=1&b=3",
+			want: []encoding.Token{
+				{Key: "b", Value: "2", Offset: 0},
+				{Key: "a", Value: "1", Offset: 4},
+				{Key: "b", Value: "3", Offset: 8},
+			},
+		},
+		{
+			name:  "unescaped",
+			input: "user%5Bname%5D=john+smith&note=a%26b",
+			want: []encoding.Token{
+				{Key: "user[name]", Value: "john smith", Offset: 0},
+				{Key: "note", Value: "a&b", Offset: 26},
+			},
+		},
+		{
+			name:  "empty pairs and missing values",
+			input: "&a&&b=&",
+			want: []encoding.Token{
+				{Key: "a", Value: "", Offset: 1},
+				{Key: "b", Value: "", Offset: 4},
+			},
+		},
+		{
+			name:  "limit",
+			input: "a=1&b=2&c=3",
+			opts:  []encoding.Option{encoding.WithMaxKeys(2)},
+			want: []encoding.Token{
+				{Key: "a", Value: "1", Offset: 0},
+				{Key: "b", Value: "2", Offset: 4},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := encoding.NewDecoder(strings.NewReader(tt.input), tt.opts...)
+			var got []encoding.Token
+			var err error
+			for decoder.More() {
+				var tok encoding.Token
+				if tok, err = decoder.Token(); err != nil {
+					break
+				}
+				got = append(got, tok)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Token() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Token() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestDecoder_TokenThenDecode(t *testing.T) {
+	t.Parallel()
+
+	decoder := encoding.NewDecoder(strings.NewReader("name=john&age=20&aliases=j"))
+	tok, err := decoder.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tok.Key != "name" {
+		t.Errorf("Token().Key = %q, want %q", tok.Key, "name")
+	}
+	if got, want := decoder.InputOffset(), int64(10); got != want {
+		t.Errorf("InputOffset() = %d, want %d", got, want)
+	}
+
+	got := &BasicForm{}
+	if err := decoder.Decode(got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if diff := diff(&BasicForm{Age: 20, Aliases: []string{"j"}}, got); diff != "" {
+		t.Errorf("Decode() mismatch %s", diff)
+	}
+	if decoder.More() {
+		t.Error("More() = true after Decode, want false")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		t.Errorf("Token() error = %v, want io.EOF", err)
+	}
+}
+
+func TestPairs(t *testing.T) {
+	t.Parallel()
+
+	type kv struct{ Key, Value string }
+
+	tests := []struct {
+		name  string
+		input string
+		opts  []encoding.Option
+		want  []kv
+	}{
+		{
+			name:  "wire order and duplicates",
+			input: "b=2&a=1&b=3&tags%5B%5D=x+y",
+			want:  []kv{{"b", "2"}, {"a", "1"}, {"b", "3"}, {"tags[]", "x y"}},
+		},
+		{
+			name:  "empty input",
+			input: "",
+		},
+		{
+			name:  "stops at error",
+			input: "a=1&b=%zz&c=3",
+			want:  []kv{{"a", "1"}},
+		},
+		{
+			name:  "stops at limit",
+			input: "a=1&b=2&c=3",
+			opts:  []encoding.Option{encoding.WithMaxKeys(2)},
+			want:  []kv{{"a", "1"}, {"b", "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var fromReader, fromBytes []kv
+			for k, v := range encoding.Pairs(strings.NewReader(tt.input), tt.opts...) {
+				fromReader = append(fromReader, kv{k, v})
+			}
+			for k, v := range encoding.PairsBytes([]byte(tt.input), tt.opts...) {
+				fromBytes = append(fromBytes, kv{k, v})
+			}
+			if diff := diff(tt.want, fromReader); diff != "" {
+				t.Errorf("Pairs() mismatch %s", diff)
+			}
+			if diff := diff(tt.want, fromBytes); diff != "" {
+				t.Errorf("PairsBytes() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestPairs_Break(t *testing.T) {
+	t.Parallel()
+
+	r := strings.NewReader("a=1&b=2&c=3")
+	for k := range encoding.Pairs(r) {
+		if k == "a" {
+			break
+		}
+	}
+	if got, want := r.Len(), len("b=2&c=3"); got != want {
+		t.Errorf("unread bytes after break = %d, want %d", got, want)
+	}
+}
+
+func TestDecoder_Err(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		opts      []encoding.Option
+		wantErr   bool
+		wantLimit bool
+	}{
+		{
+			name:  "end of input",
+			input: "a=1",
+		},
+		{
+			name:    "malformed input",
+			input:   "a=1&b=%zz",
+			wantErr: true,
+		},
+		{
+			name:      "limit",
+			input:     "a=1&b=2&c=3",
+			opts:      []encoding.Option{encoding.WithMaxKeys(2)},
+			wantErr:   true,
+			wantLimit: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := encoding.NewDecoder(strings.NewReader(tt.input), tt.opts...)
+			for range decoder.Pairs() {
+			}
+			err := decoder.Err()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+			var limitErr *encoding.LimitError
+			if tt.wantLimit && !errors.As(err, &limitErr) {
+				t.Errorf("Err() = %v, want *LimitError", err)
+			}
+		})
+	}
+}
+
+func TestEncoder_Stream(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	encoder := encoding.NewEncoder(&b)
+	if err := encoder.Encode(&BasicForm{Name: "john", Age: 20}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := encoder.WritePair("note", "a&b=c"); err != nil {
+		t.Fatalf("WritePair() error = %v", err)
+	}
+	if got, want := b.String(), "name=john&age=20"; got != want {
+		t.Errorf("before Flush() = %q, want %q", got, want)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got, want := b.String(), "name=john&age=20&note=a%26b%3Dc"; got != want {
+		t.Errorf("after Flush() = %q, want %q", got, want)
+	}
+}
+
+func TestEncoder_DiscardsFailedValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		before any
+		want   string
+	}{
+		{name: "first value", want: "b=2"},
+		{name: "after another value", before: map[string]string{"a": "0"}, want: "a=0&b=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b bytes.Buffer
+			encoder := encoding.NewEncoder(&b)
+			if tt.before != nil {
+				if err := encoder.Encode(tt.before); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+			}
+			if err := encoder.Encode(map[string]any{"a": "1", "z": Code("bad")}); err == nil {
+				t.Fatal("Encode() error = nil, want error")
+			}
+			if err := encoder.Encode(map[string]string{"b": "2"}); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Encode() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_FlushThreshold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		threshold  int
+		wantWrites int
+	}{
+		{name: "every pair", threshold: 0, wantWrites: 100},
+		{name: "threshold", threshold: 64, wantWrites: 7},
+		{name: "default", threshold: -1, wantWrites: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []encoding.Option
+			if tt.threshold >= 0 {
+				opts = append(opts, encoding.WithFlushThreshold(tt.threshold))
+			}
+
+			w := &countingWriter{}
+			input := map[string][]int{"v": make([]int, 100)}
+			if err := encoding.NewEncoder(w, opts...).Encode(input); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if w.writes != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", w.writes, tt.wantWrites)
+			}
+			if want := len("v=0")*100 + 99; w.n != want {
+				t.Errorf("bytes written = %d, want %d", w.n, want)
+			}
+		})
+	}
+}
+
+// countingWriter counts the calls made to Write and the bytes written.
+type countingWriter struct {
+	writes, n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.n += len(p)
+	return len(p), nil
+}
+
 func TestEncoder(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
 		name    string
 		input   any
+		opts    []encoding.Option
 		want    []byte
 		wantErr bool
 	}{
@@ -71,15 +614,39 @@ func TestEncoder(t *testing.T) {
 				Age:     20,
 				Aliases: []string{"johnny", "jonny"},
 			},
-			want: valuesToBytes(url.Values{
-				"age":     {"20"},
-				"aliases": {"johnny", "jonny"},
-				"name":    {"john"},
-			}),
+			want: []byte("name=john&aliases=johnny&aliases=jonny&age=20"),
+		},
+		{
+			name: "dot syntax",
+			input: &NestedForm{
+				Name:    "john",
+				Address: Address{City: "London"},
+			},
+			opts: []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			want: []byte("name=john&address.city=London&address.zip="),
+		},
+		{
+			name:  "map in key order",
+			input: map[string]any{"b": "2", "a": []int{1, 2}},
+			want:  []byte("a=1&a=2&b=2"),
+		},
+		{
+			name:  "primitive slice",
+			input: []string{"a&b", "c"},
+			want:  []byte("a%26b&c"),
+		},
+		{
+			name: "sort keys",
+			input: &NestedForm{
+				Name:    "john",
+				Address: Address{City: "London"},
+			},
+			opts: []encoding.Option{encoding.WithSortKeys()},
+			want: []byte("address%5Bcity%5D=London&address%5Bzip%5D=&name=john"),
 		},
 		{
 			name:    "invalid target",
-			input:   map[int]any{},
+			input:   map[Address]any{},
 			wantErr: true,
 		},
 	}
@@ -88,7 +655,7 @@ func TestEncoder(t *testing.T) {
 			t.Parallel()
 
 			var b bytes.Buffer
-			encoder := encoding.NewEncoder(&b)
+			encoder := encoding.NewEncoder(&b, tt.opts...)
 			err := encoder.Encode(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Encode() error = %v, wantErr %v", err, tt.wantErr)
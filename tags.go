@@ -3,34 +3,105 @@ package encoding
 import (
 	"reflect"
 	"strings"
+	"sync"
 )
 
 type tag struct {
 	Name   string
 	Omit   bool
 	Ignore bool
+
+	// Options of time.Time fields.
+	Layout    string
+	Unix      bool
+	UnixMilli bool
+	TZ        string
+
+	// Options of byte slice and array fields.
+	Bytes bytesEncoding
+}
+
+// A field describes a struct field that takes part in encoding and decoding.
+type field struct {
+	name      string
+	index     int
+	typ       reflect.Type
+	omitEmpty bool
+	time      *timeFormat   // set by the time options of the tag, if any
+	bytes     bytesEncoding // set by the hex, base64 or base64url option of the tag
+}
+
+// structFields lists the fields of a struct type in declaration order, along
+// with an index by key name for decoding.
+type structFields struct {
+	list   []field
+	byName map[string]*field
 }
 
-func tags(fv reflect.Value) []*tag {
-	tt := reflect.Indirect(fv).Type()
-	if tt.Kind() != reflect.Struct {
-		return []*tag{}
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedTypeFields is like typeFields but uses a cache to avoid parsing the
+// struct tags of the same type more than once.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
 	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
 
-	// Create a slice of tags to store the tags for each field on the struct.  The
-	// length of the slice is equal to the number of fields on the struct.
-	tags := make([]*tag, tt.NumField())
+// typeFields returns the fields of the struct type t. Unexported and ignored
+// fields are left out, and fields without a name in their tag are keyed by
+// their Go name.
+func typeFields(t reflect.Type) *structFields {
+	var list []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := parseTag(sf.Tag.Get("form"))
+		if tag.Ignore {
+			continue
+		}
+		if tag.Name == "" {
+			tag.Name = sf.Name
+		}
+		list = append(list, field{
+			name:      tag.Name,
+			index:     i,
+			typ:       sf.Type,
+			omitEmpty: tag.Omit,
+			time:      newTimeFormat(tag),
+			bytes:     tag.Bytes,
+		})
+	}
 
-	// Look for a Field on the struct that matches the key name.
-	for i := range tt.NumField() {
-		f := tt.Field(i)
-		tag := parseTag(f.Tag.Get("form"))
-		if !tag.Ignore && tag.Name == "" {
-			tag.Name = f.Name
+	// Where two fields share a name the first one wins, which is also the
+	// field that would have been matched by a linear search.
+	byName := make(map[string]*field, len(list))
+	for i := range list {
+		if _, ok := byName[list[i].name]; !ok {
+			byName[list[i].name] = &list[i]
 		}
-		tags[i] = tag
 	}
-	return tags
+	return &structFields{list: list, byName: byName}
+}
+
+// hasFormat reports whether the tag of f sets how some of the values it holds
+// are written.
+func (f *field) hasFormat() bool {
+	return f != nil && (f.time != nil || f.bytes != noBytesEncoding)
+}
+
+// formatsTime reports whether f sets the format of values of type t.
+func (f *field) formatsTime(t reflect.Type) bool {
+	return f != nil && f.time != nil && t == timeType
+}
+
+// formatsBytes reports whether f sets the encoding of values of type t.
+func (f *field) formatsBytes(t reflect.Type) bool {
+	return f != nil && f.bytes != noBytesEncoding && (isByteArray(t) || isByteSlice(t))
 }
 
 func parseTag(str string) *tag {
@@ -64,6 +135,24 @@ func parseTag(str string) *tag {
 			t.Omit = true
 		case "ignore":
 			t.Ignore = true
+		case "unix":
+			t.Unix = true
+		case "unixmilli":
+			t.UnixMilli = true
+		case "hex":
+			t.Bytes = hexBytes
+		case "base64":
+			t.Bytes = base64Bytes
+		case "base64url":
+			t.Bytes = base64URLBytes
+		}
+		if name, value, ok := strings.Cut(p, "="); ok {
+			switch name {
+			case "layout":
+				t.Layout = value
+			case "tz":
+				t.TZ = value
+			}
 		}
 	}
 
@@ -0,0 +1,171 @@
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// A timeUnit is the unit of a time written as a number, as set with the unix
+// and unixmilli tag options.
+type timeUnit int
+
+const (
+	noUnit timeUnit = iota
+	unixSeconds
+	unixMillis
+)
+
+// A timeFormat is how a time.Time field is written, as set by the options in
+// its tag:
+//
+//	Created time.Time `form:"created,layout=2006-01-02"`
+//	Updated time.Time `form:"updated,unix"`
+//	Expires time.Time `form:"expires,unixmilli,tz=Europe/London"`
+//
+// A layout may not contain a comma, since commas separate the options.
+type timeFormat struct {
+	layout string         // the layout of the time, or RFC 3339 if empty
+	unit   timeUnit       // the unit of a time written as a number
+	loc    *time.Location // the location times are written and read in, if set
+	err    error          // the error loading loc, reported on use
+}
+
+// defaultTimeFormat writes times as [time.Time.MarshalText] does.
+var defaultTimeFormat = &timeFormat{}
+
+// newTimeFormat returns the timeFormat described by the options of t, or nil
+// if it has none.
+func newTimeFormat(t *tag) *timeFormat {
+	if t.Layout == "" && !t.Unix && !t.UnixMilli && t.TZ == "" {
+		return nil
+	}
+
+	tf := &timeFormat{layout: t.Layout}
+	switch {
+	case t.UnixMilli:
+		tf.unit = unixMillis
+	case t.Unix:
+		tf.unit = unixSeconds
+	}
+	if t.TZ != "" {
+		tf.loc, tf.err = time.LoadLocation(t.TZ)
+		if tf.err != nil {
+			tf.err = fmt.Errorf("form: invalid time zone %q in tag of field %s: %w", t.TZ, t.Name, tf.err)
+		}
+	}
+	return tf
+}
+
+// format returns t in the format tf. The zero time is written as an empty
+// value, which reads back as the zero time whatever the format. Without a
+// location, a time in a layout is written in UTC, which is what it is read
+// back in, since the layout may not record its offset.
+func (tf *timeFormat) format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch {
+	case tf.loc != nil:
+		t = t.In(tf.loc)
+	case tf.layout != "":
+		t = t.UTC()
+	}
+	switch tf.unit {
+	case unixSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	case unixMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	if tf.layout == "" {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(tf.layout)
+}
+
+func (tf *timeFormat) parse(s string) (time.Time, error) {
+	loc := tf.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var (
+		t   time.Time
+		err error
+	)
+	switch tf.unit {
+	case unixSeconds, unixMillis:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 64)
+		if numErr, ok := err.(*strconv.NumError); ok {
+			err = numErr.Err
+		}
+		if tf.unit == unixSeconds {
+			t = time.Unix(n, 0)
+		} else {
+			t = time.UnixMilli(n)
+		}
+		t = t.In(loc)
+	default:
+		layout := tf.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err = time.ParseInLocation(layout, s, loc)
+		if tf.loc != nil {
+			t = t.In(tf.loc)
+		}
+	}
+	return t, err
+}
+
+// encode writes the time.Time v under key.
+func (tf *timeFormat) encode(e *encodeState, key string, v reflect.Value) error {
+	if tf.err != nil {
+		return tf.err
+	}
+	return e.w.writePair(key, tf.format(v.Interface().(time.Time)))
+}
+
+// set assigns the time in val to the time.Time v. An empty value leaves v as
+// the zero time.
+func (tf *timeFormat) set(v reflect.Value, val string) error {
+	if tf.err != nil {
+		return tf.err
+	}
+	if val == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	t, err := tf.parse(val)
+	if err != nil {
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: err}
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func durationEncoder(e *encodeState, key string, v reflect.Value) error {
+	return e.w.writePair(key, time.Duration(v.Int()).String())
+}
+
+// setDuration assigns the duration in val, as accepted by
+// [time.ParseDuration], to v. An empty value leaves v as zero.
+func setDuration(v reflect.Value, val string) error {
+	if val == "" {
+		v.SetInt(0)
+		return nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return &UnmarshalTypeError{Value: val, Type: v.Type(), Err: err}
+	}
+	v.SetInt(int64(d))
+	return nil
+}
@@ -0,0 +1,289 @@
+package encoding_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tomasbasham/encoding"
+)
+
+type EventForm struct {
+	At       time.Time            `form:"at,omitempty"`
+	Date     time.Time            `form:"date,layout=2006-01-02"`
+	Unix     time.Time            `form:"unix,unix"`
+	Millis   *time.Time           `form:"millis,unixmilli,omitempty"`
+	Local    time.Time            `form:"local,layout=2006-01-02 15:04,tz=Europe/London"`
+	Dates    []time.Time          `form:"dates,layout=2006-01-02,omitempty"`
+	Timeout  time.Duration        `form:"timeout"`
+	Schedule map[string]time.Time `form:"schedule,unix,omitempty"`
+}
+
+func TestMarshal_Time(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 2, 8, 13, 30, 0, 0, time.UTC)
+	millis := at.Add(250 * time.Millisecond)
+
+	tests := []struct {
+		name  string
+		input any
+		want  []byte
+	}{
+		{
+			name: "tag options",
+			input: EventForm{
+				At:      at,
+				Date:    at,
+				Unix:    at,
+				Millis:  &millis,
+				Local:   time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC),
+				Timeout: 90 * time.Second,
+			},
+			want: pairsToBytes(
+				"at", "2025-02-08T13:30:00Z",
+				"date", "2025-02-08",
+				"unix", "1739021400",
+				"millis", "1739021400250",
+				"local", "2025-07-01 13:00",
+				"timeout", "1m30s",
+			),
+		},
+		{
+			name: "slices and maps",
+			input: EventForm{
+				Dates:    []time.Time{at, at.AddDate(0, 0, 1)},
+				Schedule: map[string]time.Time{"start": at},
+			},
+			want: pairsToBytes(
+				"date", "",
+				"unix", "",
+				"local", "",
+				"dates", "2025-02-08",
+				"dates", "2025-02-09",
+				"timeout", "0s",
+				"schedule[start]", "1739021400",
+			),
+		},
+		{
+			name:  "time",
+			input: map[string]time.Time{"at": at},
+			want:  []byte("at=2025-02-08T13%3A30%3A00Z"),
+		},
+		{
+			name:  "duration",
+			input: map[string]time.Duration{"timeout": 1500 * time.Millisecond},
+			want:  []byte("timeout=1.5s"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Time(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 2, 8, 13, 30, 0, 0, time.UTC)
+	millis := at.Add(250 * time.Millisecond)
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  EventForm
+	}{
+		{
+			name: "tag options",
+			input: pairsToBytes(
+				"at", "2025-02-08T13:30:00Z",
+				"date", "2025-02-08",
+				"unix", "1739021400",
+				"millis", "1739021400250",
+				"local", "2025-07-01 13:00",
+				"timeout", "1m30s",
+			),
+			want: EventForm{
+				At:      at,
+				Date:    time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC),
+				Unix:    at,
+				Millis:  &millis,
+				Local:   time.Date(2025, 7, 1, 13, 0, 0, 0, london),
+				Timeout: 90 * time.Second,
+			},
+		},
+		{
+			name: "slices and maps",
+			input: pairsToBytes(
+				"dates", "2025-02-08",
+				"dates", "2025-02-09",
+				"schedule[start]", "1739021400",
+			),
+			want: EventForm{
+				Dates:    []time.Time{time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC)},
+				Schedule: map[string]time.Time{"start": at},
+			},
+		},
+		{
+			name:  "empty values",
+			input: []byte("at=&date=&timeout="),
+			want:  EventForm{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got EventForm
+			if err := encoding.Unmarshal(tt.input, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Unmarshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestTime_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	millis := time.Date(2025, 2, 8, 13, 30, 0, 250e6, time.UTC)
+	want := EventForm{
+		At:       time.Date(2025, 2, 8, 13, 30, 0, 123, time.UTC),
+		Date:     time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC),
+		Unix:     time.Date(2025, 2, 8, 13, 30, 0, 0, time.UTC),
+		Millis:   &millis,
+		Dates:    []time.Time{time.Date(2025, 2, 9, 0, 0, 0, 0, time.UTC)},
+		Timeout:  36 * time.Hour,
+		Schedule: map[string]time.Time{"end": time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	b, err := encoding.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got EventForm
+	if err := encoding.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if diff := diff(want, got); diff != "" {
+		t.Errorf("round trip mismatch %s", diff)
+	}
+}
+
+func TestTime_RoundTripNonUTC(t *testing.T) {
+	t.Parallel()
+
+	newYork := time.FixedZone("EST", -5*60*60)
+	want := EventForm{
+		At:       time.Date(2024, 1, 2, 10, 30, 0, 0, newYork),
+		Unix:     time.Date(2024, 1, 2, 10, 30, 0, 0, newYork),
+		Local:    time.Date(2024, 1, 2, 10, 30, 0, 0, newYork),
+		Dates:    []time.Time{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Schedule: map[string]time.Time{"end": time.Date(2024, 1, 2, 22, 0, 0, 0, newYork)},
+	}
+	stamp := struct {
+		At time.Time `form:"at,layout=2006-01-02 15:04"`
+	}{At: time.Date(2024, 1, 2, 10, 30, 0, 0, newYork)}
+
+	b, err := encoding.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got EventForm
+	if err := encoding.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if diff := diff(want, got); diff != "" {
+		t.Errorf("round trip mismatch %s", diff)
+	}
+
+	b, err = encoding.Marshal(stamp)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if got, want := string(b), "at=2024-01-02+15%3A30"; got != want {
+		t.Errorf("Marshal() = %q, want %q", got, want)
+	}
+	gotStamp := stamp
+	gotStamp.At = time.Time{}
+	if err := encoding.Unmarshal(b, &gotStamp); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !gotStamp.At.Equal(stamp.At) {
+		t.Errorf("round trip = %v, want %v", gotStamp.At, stamp.At)
+	}
+}
+
+func TestTime_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     []byte
+		wantField string
+	}{
+		{
+			name:      "layout",
+			input:     []byte("date=08/02/2025"),
+			wantField: "date",
+		},
+		{
+			name:      "unix",
+			input:     []byte("unix=yesterday"),
+			wantField: "unix",
+		},
+		{
+			name:      "slice element",
+			input:     []byte("dates=2025-02-08&dates=tomorrow"),
+			wantField: "dates[1]",
+		},
+		{
+			name:      "duration",
+			input:     []byte("timeout=90"),
+			wantField: "timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, &EventForm{})
+			var typeErr *encoding.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("Unmarshal() error = %v, want *UnmarshalTypeError", err)
+			}
+			if typeErr.Field != tt.wantField {
+				t.Errorf("UnmarshalTypeError.Field = %q, want %q", typeErr.Field, tt.wantField)
+			}
+		})
+	}
+
+	t.Run("invalid time zone", func(t *testing.T) {
+		t.Parallel()
+
+		type form struct {
+			At time.Time `form:"at,tz=Nowhere/Special"`
+		}
+		if _, err := encoding.Marshal(form{}); err == nil {
+			t.Error("Marshal() error = nil, want an error")
+		}
+		if err := encoding.Unmarshal([]byte("at=2025-02-08T13:30:00Z"), &form{}); err == nil {
+			t.Error("Unmarshal() error = nil, want an error")
+		}
+	})
+}
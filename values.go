@@ -0,0 +1,163 @@
+package encoding
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// A Pair is a single key-value pair of a [Values].
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Values is form data as an ordered list of pairs. Unlike [url.Values] it
+// keeps the order the pairs were added or decoded in, including how the values
+// of repeated keys interleave, so decoding and re-encoding it is lossless.
+//
+// Values can be the target of [Unmarshal] and the argument of [Marshal], where
+// it stands for the whole form. As a field it holds every pair nested below
+// the key of the field: a field tagged extra receives extra[a]=1 as the pair
+// a=1, and encodes it back the same way.
+type Values []Pair
+
+// Get returns the first value associated with the given key. If there are no
+// values associated with the key, Get returns the empty string.
+func (v Values) Get(key string) string {
+	for _, p := range v {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Has checks whether a given key is set.
+func (v Values) Has(key string) bool {
+	for _, p := range v {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds the value to key, after every existing pair.
+func (v *Values) Add(key, value string) {
+	*v = append(*v, Pair{Key: key, Value: value})
+}
+
+// Set sets the key to value. It replaces the value of the first pair with the
+// key, keeping its position, and removes any others. If there is no such pair,
+// one is added.
+func (v *Values) Set(key, value string) {
+	i := v.index(key)
+	if i < 0 {
+		v.Add(key, value)
+		return
+	}
+	(*v)[i].Value = value
+	tail := (*v)[i+1:]
+	tail.Del(key)
+	*v = (*v)[:i+1+len(tail)]
+}
+
+// Del deletes the values associated with key.
+func (v *Values) Del(key string) {
+	out := (*v)[:0]
+	for _, p := range *v {
+		if p.Key != key {
+			out = append(out, p)
+		}
+	}
+	clear((*v)[len(out):])
+	*v = out
+}
+
+// Encode encodes the values into URL-encoded form ("bar=baz&foo=quux") in the
+// order of the pairs.
+func (v Values) Encode() string {
+	var w bufferWriter
+	for _, p := range v {
+		w.writePair(p.Key, p.Value)
+	}
+	return string(w.buf)
+}
+
+// MarshalForm implements [Marshaler].
+func (v Values) MarshalForm() ([]byte, error) {
+	return []byte(v.Encode()), nil
+}
+
+// UnmarshalForm implements [Unmarshaler]. It replaces the pairs of v with those
+// of the form data b.
+func (v *Values) UnmarshalForm(b []byte) error {
+	return v.scan(newScanner(bytes.NewReader(b), &options{}))
+}
+
+// scan replaces the pairs of v with those read by s, one at a time.
+func (v *Values) scan(s *scanner) error {
+	out := (*v)[:0]
+	for {
+		tok, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		out = append(out, Pair{Key: tok.Key, Value: tok.Value})
+	}
+	*v = out
+	return nil
+}
+
+func (v Values) index(key string) int {
+	for i, p := range v {
+		if p.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+var valuesType = reflect.TypeFor[Values]()
+
+// valuesEncoder writes the pairs of a Values field nested below its key. A
+// pair key that is itself nested, such as a[x], continues the key of the
+// field, as in extra[a][x], so that it decodes back to the same pair.
+func valuesEncoder(e *encodeState, key string, v reflect.Value) error {
+	for _, p := range v.Interface().(Values) {
+		pairKey := key
+		for _, segment := range e.opts.keySyntax.split(p.Key) {
+			pairKey = e.opts.keySyntax.nest(pairKey, segment)
+		}
+		if err := e.w.writePair(pairKey, p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalValuesField adds the pair whose key continues below a Values field
+// to it, keeping the rest of the key verbatim. A value given for the key of the
+// field itself is added under the empty key, as an empty key is written. The
+// field is emptied the first time it is reached, just as a slice is.
+func unmarshalValuesField(d *decodeState, v reflect.Value, path string, segments []string, assign assignFunc) error {
+	if !d.seen[path] {
+		v.SetLen(0)
+		d.seen[path] = true
+	}
+	var value string
+	if err := assign(reflect.ValueOf(&value).Elem()); err != nil {
+		return err
+	}
+	var key string
+	if len(segments) > 0 {
+		key = d.opts.keySyntax.join(segments)
+	}
+	vals := v.Addr().Interface().(*Values)
+	vals.Add(key, value)
+	return nil
+}
@@ -0,0 +1,210 @@
+package encoding_test
+
+import (
+	"testing"
+
+	"github.com/tomasbasham/encoding"
+)
+
+type ValuesForm struct {
+	Name  string          `form:"name"`
+	Extra encoding.Values `form:"extra,omitempty"`
+}
+
+func TestValues(t *testing.T) {
+	t.Parallel()
+
+	v := encoding.Values{}
+	v.Add("b", "1")
+	v.Add("a", "2")
+	v.Add("b", "3")
+	v.Add("c", "4")
+
+	if got := v.Get("b"); got != "1" {
+		t.Errorf("Get(b) = %q, want %q", got, "1")
+	}
+	if got := v.Get("z"); got != "" {
+		t.Errorf("Get(z) = %q, want empty", got)
+	}
+	if !v.Has("a") || v.Has("z") {
+		t.Errorf("Has() = %v, %v, want true, false", v.Has("a"), v.Has("z"))
+	}
+	if got, want := v.Encode(), "b=1&a=2&b=3&c=4"; got != want {
+		t.Errorf("Encode() = %q, want %q", got, want)
+	}
+
+	v.Set("b", "x y")
+	if got, want := v.Encode(), "b=x+y&a=2&c=4"; got != want {
+		t.Errorf("Encode() after Set = %q, want %q", got, want)
+	}
+	v.Set("d", "5")
+	v.Del("a")
+	if got, want := v.Encode(), "b=x+y&c=4&d=5"; got != want {
+		t.Errorf("Encode() after Del = %q, want %q", got, want)
+	}
+}
+
+func TestValues_Unmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   []byte
+		target  any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:   "top-level",
+			input:  []byte("b=1&a=2&b=3"),
+			target: &encoding.Values{},
+			want:   &encoding.Values{{"b", "1"}, {"a", "2"}, {"b", "3"}},
+		},
+		{
+			name:   "replaces existing pairs",
+			input:  []byte("a=1"),
+			target: &encoding.Values{{"z", "9"}},
+			want:   &encoding.Values{{"a", "1"}},
+		},
+		{
+			name:   "field",
+			input:  []byte("extra[b]=1&name=john&extra[a][x]=2&extra[b]=3"),
+			target: &ValuesForm{Extra: encoding.Values{{"z", "9"}}},
+			want: &ValuesForm{
+				Name:  "john",
+				Extra: encoding.Values{{"b", "1"}, {"a[x]", "2"}, {"b", "3"}},
+			},
+		},
+		{
+			name:   "bare field key",
+			input:  []byte("extra=k%3Dv%26admin%3D1&extra[a]=2&extra=foo"),
+			target: &ValuesForm{},
+			want: &ValuesForm{
+				Extra: encoding.Values{{"", "k=v&admin=1"}, {"a", "2"}, {"", "foo"}},
+			},
+		},
+		{
+			name:   "empty brackets on field key",
+			input:  []byte("extra[]=foo"),
+			target: &ValuesForm{},
+			want:   &ValuesForm{Extra: encoding.Values{{"", "foo"}}},
+		},
+		{
+			name:    "invalid",
+			input:   []byte("a=%zz"),
+			target:  &encoding.Values{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := encoding.Unmarshal(tt.input, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				if diff := diff(tt.want, tt.target); diff != "" {
+					t.Errorf("Unmarshal() mismatch %s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestValues_Marshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []encoding.Option
+		want  []byte
+	}{
+		{
+			name:  "top-level",
+			input: encoding.Values{{"b", "1"}, {"a", "x&y"}, {"b", "3"}},
+			want:  []byte("b=1&a=x%26y&b=3"),
+		},
+		{
+			name:  "top-level sorted",
+			input: encoding.Values{{"b", "1"}, {"a", "2"}, {"b", "3"}},
+			opts:  []encoding.Option{encoding.WithSortKeys()},
+			want:  []byte("a=2&b=1&b=3"),
+		},
+		{
+			name: "field",
+			input: ValuesForm{
+				Name:  "john",
+				Extra: encoding.Values{{"b", "1"}, {"a[x]", "2"}},
+			},
+			want: pairsToBytes("name", "john", "extra[b]", "1", "extra[a][x]", "2"),
+		},
+		{
+			name: "field with dot syntax",
+			input: ValuesForm{
+				Name:  "john",
+				Extra: encoding.Values{{"b", "1"}},
+			},
+			opts: []encoding.Option{encoding.WithKeySyntax(encoding.DotSyntax)},
+			want: []byte("name=john&extra.b=1"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := encoding.Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.want, got); diff != "" {
+				t.Errorf("Marshal() mismatch %s", diff)
+			}
+		})
+	}
+}
+
+func TestValues_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		input  []byte
+		target any
+	}{
+		{
+			name:   "top-level",
+			input:  []byte("b=1&a=2&b=3&c=x+y&a=%26"),
+			target: &encoding.Values{},
+		},
+		{
+			name:   "field",
+			input:  pairsToBytes("name", "john", "extra[b]", "1", "extra[a][x]", "2", "extra[b]", "3"),
+			target: &ValuesForm{},
+		},
+		{
+			name:   "field with empty key",
+			input:  pairsToBytes("name", "john", "extra[]", "k=v&admin=1", "extra[a]", "2"),
+			target: &ValuesForm{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := encoding.Unmarshal(tt.input, tt.target); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			got, err := encoding.Marshal(tt.target)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if diff := diff(tt.input, got); diff != "" {
+				t.Errorf("round trip mismatch %s", diff)
+			}
+		})
+	}
+}